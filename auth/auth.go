@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// SchemeEIP191 is secp256k1 personal_sign over an Ethereum address.
+	SchemeEIP191 = "eip191"
+	// SchemeEd25519 is a plain ed25519 signature where the address is the hex encoded public key.
+	SchemeEd25519 = "ed25519"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrUnknownScheme    = errors.New("unknown signature scheme")
+)
+
+// Verifier checks that a signature over a message was produced by the key behind an address.
+type Verifier interface {
+	Verify(address string, message, signature []byte) error
+}
+
+// Registry maps signature scheme names to their verifiers.
+type Registry struct {
+	mu        sync.RWMutex
+	verifiers map[string]Verifier
+}
+
+// NewRegistry creates a registry with the built-in signature schemes registered.
+func NewRegistry() *Registry {
+	r := &Registry{verifiers: make(map[string]Verifier)}
+	r.Register(SchemeEIP191, EIP191Verifier{})
+	r.Register(SchemeEd25519, Ed25519Verifier{})
+	return r
+}
+
+// Register adds or replaces the verifier for a scheme.
+func (r *Registry) Register(scheme string, v Verifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifiers[scheme] = v
+}
+
+// Verify checks a signature using the verifier registered for scheme.
+// An empty scheme defaults to EIP-191.
+func (r *Registry) Verify(scheme, address string, message, signature []byte) error {
+	if scheme == "" {
+		scheme = SchemeEIP191
+	}
+
+	r.mu.RLock()
+	v, ok := r.verifiers[scheme]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownScheme, scheme)
+	}
+
+	return v.Verify(address, message, signature)
+}
+
+// DecodeHex decodes a hex string with an optional 0x prefix.
+func DecodeHex(s string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+}
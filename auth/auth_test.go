@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := DecodeHex(s)
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+// The web3.js accounts.sign example: "Some data" signed by the key below.
+const (
+	web3PrivateKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	web3Address    = "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	web3Message    = "Some data"
+	web3Hash       = "1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"
+	web3Signature  = "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+)
+
+func TestPersonalMessageHash(t *testing.T) {
+	if got := hex.EncodeToString(PersonalMessageHash([]byte(web3Message))); got != web3Hash {
+		t.Errorf("PersonalMessageHash = %s, want %s", got, web3Hash)
+	}
+}
+
+func TestEIP191Verify(t *testing.T) {
+	sig := mustHex(t, web3Signature)
+	lowV := append([]byte{}, sig...)
+	lowV[64] -= 27
+	badV := append([]byte{}, sig...)
+	badV[64] = 29
+	tampered := append([]byte{}, sig...)
+	tampered[10] ^= 1
+
+	tests := []struct {
+		name      string
+		address   string
+		message   string
+		signature []byte
+		valid     bool
+	}{
+		{"checksummed address", web3Address, web3Message, sig, true},
+		{"lower case address", "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23", web3Message, sig, true},
+		{"recovery id without offset", web3Address, web3Message, lowV, true},
+		{"other message", web3Address, "Some date", sig, false},
+		{"other address", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", web3Message, sig, false},
+		{"tampered signature", web3Address, web3Message, tampered, false},
+		{"bad recovery id", web3Address, web3Message, badV, false},
+		{"short signature", web3Address, web3Message, sig[:64], false},
+	}
+	for _, tt := range tests {
+		err := EIP191Verifier{}.Verify(tt.address, []byte(tt.message), tt.signature)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: got %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestPublicKeyToAddress(t *testing.T) {
+	priv := secp256k1.PrivKeyFromBytes(mustHex(t, web3PrivateKey))
+	got := PublicKeyToAddress(priv.PubKey().SerializeUncompressed())
+	if got != "0x2c7536e3605d9c16a7a3d7b1898e529396a65c23" {
+		t.Errorf("PublicKeyToAddress = %s, want the address of the web3.js example key", got)
+	}
+}
+
+// RFC 8032 section 7.1, tests 1 and 2.
+func TestEd25519Verify(t *testing.T) {
+	const (
+		pub1 = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+		sig1 = "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+		pub2 = "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c"
+		sig2 = "92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00"
+	)
+	tests := []struct {
+		name      string
+		address   string
+		message   []byte
+		signature string
+		valid     bool
+	}{
+		{"empty message", pub1, nil, sig1, true},
+		{"one byte message", pub2, []byte{0x72}, sig2, true},
+		{"prefixed address", "0x" + pub2, []byte{0x72}, sig2, true},
+		{"other message", pub2, []byte{0x73}, sig2, false},
+		{"other key", pub1, []byte{0x72}, sig2, false},
+		{"short address", pub1[:62], nil, sig1, false},
+		{"address not hex", "zz" + pub1[2:], nil, sig1, false},
+	}
+	for _, tt := range tests {
+		err := Ed25519Verifier{}.Verify(tt.address, tt.message, mustHex(t, tt.signature))
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: got %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
+
+func TestRegistryVerify(t *testing.T) {
+	r := NewRegistry()
+	sig := mustHex(t, web3Signature)
+	if err := r.Verify("", web3Address, []byte(web3Message), sig); err != nil {
+		t.Errorf("empty scheme does not default to EIP-191: %v", err)
+	}
+	if err := r.Verify("schnorr", web3Address, []byte(web3Message), sig); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("unknown scheme: got %v, want ErrUnknownScheme", err)
+	}
+}
+
+func TestVerifyDigest(t *testing.T) {
+	priv := secp256k1.PrivKeyFromBytes(mustHex(t, web3PrivateKey))
+	compressed := hex.EncodeToString(priv.PubKey().SerializeCompressed())
+	uncompressed := hex.EncodeToString(priv.PubKey().SerializeUncompressed())
+	digest := mustHex(t, web3Hash)
+	sig := mustHex(t, web3Signature)
+	otherDigest := append([]byte{}, digest...)
+	otherDigest[0] ^= 1
+	overflow := append(mustHex(t, "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"), sig[32:]...)
+
+	tests := []struct {
+		name      string
+		publicKey string
+		digest    []byte
+		signature []byte
+		valid     bool
+	}{
+		{"compressed key with recovery byte", compressed, digest, sig, true},
+		{"uncompressed key without recovery byte", "0x" + uncompressed, digest, sig[:64], true},
+		{"other digest", compressed, otherDigest, sig, false},
+		{"component overflows", compressed, digest, overflow, false},
+		{"short signature", compressed, digest, sig[:63], false},
+		{"malformed key", "0x02", digest, sig, false},
+		{"key not hex", "xyz", digest, sig, false},
+	}
+	for _, tt := range tests {
+		err := VerifyDigest(tt.publicKey, tt.digest, tt.signature)
+		if tt.valid && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: got %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+}
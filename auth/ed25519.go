@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"fmt"
+)
+
+// Ed25519Verifier verifies ed25519 signatures where the address is the hex encoded public key.
+type Ed25519Verifier struct{}
+
+// Verify checks an ed25519 signature over the raw message.
+func (Ed25519Verifier) Verify(address string, message, signature []byte) error {
+	pub, err := DecodeHex(address)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: address is not an ed25519 public key", ErrInvalidSignature)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), message, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
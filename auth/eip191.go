@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+// EIP191Verifier verifies secp256k1 personal_sign signatures against Ethereum addresses.
+type EIP191Verifier struct{}
+
+// Verify recovers the signer of an EIP-191 personal message and compares it to address.
+// The signature is the 65 byte r || s || v form produced by wallets.
+func (EIP191Verifier) Verify(address string, message, signature []byte) error {
+	if len(signature) != 65 {
+		return fmt.Errorf("%w: expected 65 bytes, got %d", ErrInvalidSignature, len(signature))
+	}
+
+	v := signature[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return fmt.Errorf("%w: bad recovery id", ErrInvalidSignature)
+	}
+
+	// RecoverCompact expects <27 + recovery id><r><s>.
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], signature[:64])
+
+	pub, _, err := ecdsa.RecoverCompact(compact, PersonalMessageHash(message))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	recovered := PublicKeyToAddress(pub.SerializeUncompressed())
+	if !strings.EqualFold(recovered, address) {
+		return fmt.Errorf("%w: signed by %s", ErrInvalidSignature, recovered)
+	}
+	return nil
+}
+
+// PersonalMessageHash returns the EIP-191 version 0x45 hash of a message.
+func PersonalMessageHash(message []byte) []byte {
+	return Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(message))), message)
+}
+
+// PublicKeyToAddress derives the 0x prefixed Ethereum address of an uncompressed secp256k1 public key.
+func PublicKeyToAddress(uncompressed []byte) string {
+	return "0x" + hex.EncodeToString(Keccak256(uncompressed[1:])[12:])
+}
+
+// Keccak256 hashes the concatenation of data with the legacy Keccak-256 used by Ethereum.
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// VerifyDigest checks a secp256k1 ECDSA signature over a 32 byte digest against a
+// hex encoded compressed or uncompressed public key. The signature is r || s with
+// an optional trailing recovery byte.
+func VerifyDigest(publicKey string, digest, signature []byte) error {
+	keyBytes, err := DecodeHex(publicKey)
+	if err != nil {
+		return fmt.Errorf("%w: malformed public key", ErrInvalidSignature)
+	}
+	pub, err := secp256k1.ParsePubKey(keyBytes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if len(signature) != 64 && len(signature) != 65 {
+		return fmt.Errorf("%w: expected 64 or 65 bytes, got %d", ErrInvalidSignature, len(signature))
+	}
+
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:64]) {
+		return fmt.Errorf("%w: signature component overflows the curve order", ErrInvalidSignature)
+	}
+	if !ecdsa.NewSignature(&r, &s).Verify(digest, pub) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
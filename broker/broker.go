@@ -0,0 +1,188 @@
+package broker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// Kinds of hub events.
+const (
+	// KindUser is delivered to every connection of Address.
+	KindUser = "user"
+	// KindRoom is delivered to every connection subscribed to Room.
+	KindRoom = "room"
+	// KindConnection is delivered to the connection with ID Connection on instance Instance.
+	KindConnection = "connection"
+	// KindForward carries a client frame that the receiving instance could not
+	// handle to instance Instance, e.g. the one running its ceremony session.
+	KindForward = "forward"
+)
+
+// Event is a hub event fanned out to every instance. Frame is the encoded
+// WebSocket frame, ready to be written to a connection.
+type Event struct {
+	Kind       string          `json:"kind"`
+	Origin     string          `json:"origin"`
+	Instance   string          `json:"instance,omitempty"`
+	Address    string          `json:"address,omitempty"`
+	Room       string          `json:"room,omitempty"`
+	Connection string          `json:"connection,omitempty"`
+	Seq        int64           `json:"seq,omitempty"`
+	Frame      json.RawMessage `json:"frame"`
+}
+
+// Broker fans hub events out to every instance, the publishing one included,
+// and tracks which addresses are connected anywhere in the cluster.
+type Broker interface {
+	// ID identifies this instance among the others.
+	ID() string
+	// Clustered reports whether other instances may receive published events.
+	Clustered() bool
+	// Publish sends an event to every instance.
+	Publish(e Event) error
+	// Listen sets the function events are delivered to and starts delivery.
+	Listen(handle func(Event))
+	// Join records a new connection of address on this instance and returns
+	// the number of connections the address has across the cluster.
+	Join(address string) (int, error)
+	// Leave records a closed connection of address on this instance and returns
+	// the number of connections the address has left across the cluster.
+	Leave(address string) (int, error)
+	// Online reports which of the addresses have a connection anywhere.
+	Online(addresses []string) (map[string]bool, error)
+	// Subscribe records that a connection of address on this instance joined room.
+	Subscribe(room, address string) error
+	// Unsubscribe records that a connection of address on this instance left room.
+	Unsubscribe(room, address string) error
+	// Subscribed reports which of the addresses have a connection in room anywhere.
+	Subscribed(room string, addresses []string) (map[string]bool, error)
+	// Close stops delivery and forgets the connections of this instance.
+	Close()
+}
+
+// Local is a Broker for a single instance. Events are delivered synchronously.
+type Local struct {
+	id string
+
+	mu            sync.RWMutex
+	handle        func(Event)
+	connections   map[string]int
+	subscriptions map[string]map[string]int
+}
+
+// NewLocal creates an in-process Broker.
+func NewLocal() (*Local, error) {
+	id, err := newInstanceID()
+	if err != nil {
+		return nil, err
+	}
+	return &Local{id: id, connections: make(map[string]int), subscriptions: make(map[string]map[string]int)}, nil
+}
+
+func (l *Local) ID() string { return l.id }
+
+func (l *Local) Clustered() bool { return false }
+
+func (l *Local) Publish(e Event) error {
+	l.mu.RLock()
+	handle := l.handle
+	l.mu.RUnlock()
+	if handle != nil {
+		handle(e)
+	}
+	return nil
+}
+
+func (l *Local) Listen(handle func(Event)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handle = handle
+}
+
+func (l *Local) Join(address string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connections[address]++
+	return l.connections[address], nil
+}
+
+func (l *Local) Leave(address string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.connections[address]--
+	n := l.connections[address]
+	if n <= 0 {
+		delete(l.connections, address)
+		n = 0
+	}
+	return n, nil
+}
+
+func (l *Local) Online(addresses []string) (map[string]bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	online := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		if l.connections[addr] > 0 {
+			online[addr] = true
+		}
+	}
+	return online, nil
+}
+
+func (l *Local) Subscribe(room, address string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.subscriptions[room] == nil {
+		l.subscriptions[room] = make(map[string]int)
+	}
+	l.subscriptions[room][address]++
+	return nil
+}
+
+func (l *Local) Unsubscribe(room, address string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	members, ok := l.subscriptions[room]
+	if !ok {
+		return nil
+	}
+	members[address]--
+	if members[address] <= 0 {
+		delete(members, address)
+	}
+	if len(members) == 0 {
+		delete(l.subscriptions, room)
+	}
+	return nil
+}
+
+func (l *Local) Subscribed(room string, addresses []string) (map[string]bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	subscribed := make(map[string]bool, len(addresses))
+	for _, addr := range addresses {
+		if l.subscriptions[room][addr] > 0 {
+			subscribed[addr] = true
+		}
+	}
+	return subscribed, nil
+}
+
+func (l *Local) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handle = nil
+	l.connections = make(map[string]int)
+	l.subscriptions = make(map[string]map[string]int)
+}
+
+func newInstanceID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,388 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mpc-backend/config"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// channel is the Postgres notification channel hub events are announced on.
+const channel = "hub_events"
+
+// Postgres is a Broker for several instances sharing a database. Events are
+// stored in hub_events and their IDs announced with NOTIFY, since a NOTIFY
+// payload is too small for a frame. Listeners read every event past the last
+// one they saw, so a missed notification is picked up with the next one.
+// Concurrent publishers may commit out of ID order, so IDs skipped by a later
+// event are read again until they show up or GapTimeout passes.
+// Presence is kept in hub_presence, one row per instance and address, and room
+// subscriptions in hub_subscriptions, one row per instance, room and address.
+// Rows of instances that stop sending heartbeats are ignored.
+type Postgres struct {
+	pool *pgxpool.Pool
+	conf config.BrokerConf
+	id   string
+
+	mu     sync.RWMutex
+	handle func(Event)
+
+	// cursor is only used by the listener.
+	cursor cursor
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewPostgres creates a Broker backed by LISTEN/NOTIFY on the given pool.
+func NewPostgres(pool *pgxpool.Pool, conf config.BrokerConf) (*Postgres, error) {
+	id, err := newInstanceID()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Postgres{pool: pool, conf: conf, id: id, cursor: cursor{gaps: make(map[int64]time.Time)}}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	// Only events published from now on are of interest.
+	err = pool.QueryRow(p.ctx, `SELECT COALESCE(MAX(id), 0) FROM hub_events`).Scan(&p.cursor.last)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read last hub event: %w", err)
+	}
+
+	go p.heartbeat()
+	return p, nil
+}
+
+func (p *Postgres) ID() string { return p.id }
+
+func (p *Postgres) Clustered() bool { return true }
+
+func (p *Postgres) Publish(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = p.pool.Exec(
+		p.ctx,
+		`WITH event AS (INSERT INTO hub_events (payload) VALUES ($1) RETURNING id)
+		 SELECT pg_notify($2, id::text) FROM event`,
+		payload, channel,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish hub event: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Listen(handle func(Event)) {
+	p.mu.Lock()
+	p.handle = handle
+	p.mu.Unlock()
+	go p.listen()
+}
+
+// listen waits for notifications on a dedicated connection and reconnects
+// with a growing delay when the connection breaks.
+func (p *Postgres) listen() {
+	backoff := time.Second
+	for {
+		err := p.listenOnce()
+		if p.ctx.Err() != nil {
+			return
+		}
+		log.Error().Err(err).Dur("retry_in", backoff).Msg("Hub event listener stopped")
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func (p *Postgres) listenOnce() error {
+	conn, err := p.pool.Acquire(p.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listener connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(p.ctx, "LISTEN "+channel); err != nil {
+		return fmt.Errorf("failed to listen for hub events: %w", err)
+	}
+
+	// Deliver what was published while the listener was down.
+	if err := p.catchUp(); err != nil {
+		return err
+	}
+	for {
+		if _, err := conn.Conn().WaitForNotification(p.ctx); err != nil {
+			return fmt.Errorf("failed to wait for hub events: %w", err)
+		}
+		if err := p.catchUp(); err != nil {
+			return err
+		}
+	}
+}
+
+// maxGap bounds the number of skipped IDs tracked after a single event, e.g.
+// when many publishers fail at once.
+const maxGap = 1024
+
+// cursor tracks which hub events a listener has delivered: every ID up to
+// last, apart from the gaps, IDs that were not committed yet when a later
+// event was read, kept with when they were first missed.
+type cursor struct {
+	last int64
+	gaps map[int64]time.Time
+}
+
+// pending forgets the gaps missed for longer than timeout and returns the
+// ones still worth reading again.
+func (c *cursor) pending(now time.Time, timeout time.Duration) []int64 {
+	gaps := make([]int64, 0, len(c.gaps))
+	for id, missed := range c.gaps {
+		if now.Sub(missed) > timeout {
+			// The publisher rolled back or the event was never committed.
+			delete(c.gaps, id)
+			continue
+		}
+		gaps = append(gaps, id)
+	}
+	return gaps
+}
+
+// advance reports whether the event with the given ID is yet to be delivered,
+// and records the IDs it skips as gaps.
+func (c *cursor) advance(id int64, now time.Time) bool {
+	if id <= c.last {
+		if _, ok := c.gaps[id]; !ok {
+			return false
+		}
+		delete(c.gaps, id)
+		return true
+	}
+	for missing := max(c.last+1, id-maxGap); missing < id; missing++ {
+		c.gaps[missing] = now
+	}
+	c.last = id
+	return true
+}
+
+// catchUp delivers every event stored after the last delivered one and the
+// skipped ones that were committed since.
+func (p *Postgres) catchUp() error {
+	now := time.Now()
+	gaps := p.cursor.pending(now, p.conf.GapTimeout)
+
+	rows, err := p.pool.Query(
+		p.ctx,
+		`SELECT id, payload FROM hub_events WHERE id > $1 OR id = ANY($2) ORDER BY id`, p.cursor.last, gaps)
+	if err != nil {
+		return fmt.Errorf("failed to fetch hub events: %w", err)
+	}
+	defer rows.Close()
+
+	p.mu.RLock()
+	handle := p.handle
+	p.mu.RUnlock()
+
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			return fmt.Errorf("failed to scan hub event: %w", err)
+		}
+		if !p.cursor.advance(id, now) {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal(payload, &e); err != nil {
+			log.Error().Err(err).Int64("event", id).Msg("Skipping malformed hub event")
+			continue
+		}
+		handle(e)
+	}
+	return rows.Err()
+}
+
+// heartbeat keeps the presence rows of this instance fresh and prunes old
+// events and the presence of instances that went away.
+func (p *Postgres) heartbeat() {
+	ticker := time.NewTicker(p.conf.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := p.pool.Exec(p.ctx, `UPDATE hub_presence SET updated_at = NOW() WHERE instance_id = $1`, p.id); err != nil {
+			log.Error().Err(err).Msg("Failed to refresh hub presence")
+		}
+		if _, err := p.pool.Exec(p.ctx, `UPDATE hub_subscriptions SET updated_at = NOW() WHERE instance_id = $1`, p.id); err != nil {
+			log.Error().Err(err).Msg("Failed to refresh hub subscriptions")
+		}
+		if _, err := p.pool.Exec(p.ctx, `DELETE FROM hub_presence WHERE updated_at < $1`, time.Now().Add(-p.conf.PresenceTTL)); err != nil {
+			log.Error().Err(err).Msg("Failed to prune stale hub presence")
+		}
+		if _, err := p.pool.Exec(p.ctx, `DELETE FROM hub_subscriptions WHERE updated_at < $1`, time.Now().Add(-p.conf.PresenceTTL)); err != nil {
+			log.Error().Err(err).Msg("Failed to prune stale hub subscriptions")
+		}
+		if _, err := p.pool.Exec(p.ctx, `DELETE FROM hub_events WHERE created_at < $1`, time.Now().Add(-p.conf.EventRetention)); err != nil {
+			log.Error().Err(err).Msg("Failed to prune hub events")
+		}
+	}
+}
+
+func (p *Postgres) Join(address string) (int, error) {
+	_, err := p.pool.Exec(
+		p.ctx,
+		`INSERT INTO hub_presence (instance_id, address, connections) VALUES ($1, $2, 1)
+		 ON CONFLICT (instance_id, address)
+		 DO UPDATE SET connections = hub_presence.connections + 1, updated_at = NOW()`,
+		p.id, address,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record presence: %w", err)
+	}
+	return p.count(address)
+}
+
+func (p *Postgres) Leave(address string) (int, error) {
+	_, err := p.pool.Exec(
+		p.ctx,
+		`UPDATE hub_presence SET connections = connections - 1, updated_at = NOW()
+		 WHERE instance_id = $1 AND address = $2`,
+		p.id, address,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record presence: %w", err)
+	}
+	_, err = p.pool.Exec(
+		p.ctx,
+		`DELETE FROM hub_presence WHERE instance_id = $1 AND address = $2 AND connections <= 0`,
+		p.id, address,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record presence: %w", err)
+	}
+	return p.count(address)
+}
+
+func (p *Postgres) count(address string) (int, error) {
+	var n int
+	err := p.pool.QueryRow(
+		p.ctx,
+		`SELECT COALESCE(SUM(connections), 0) FROM hub_presence
+		 WHERE address = $1 AND connections > 0 AND updated_at >= $2`,
+		address, time.Now().Add(-p.conf.PresenceTTL),
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count connections: %w", err)
+	}
+	return n, nil
+}
+
+func (p *Postgres) Online(addresses []string) (map[string]bool, error) {
+	rows, err := p.pool.Query(
+		p.ctx,
+		`SELECT DISTINCT address FROM hub_presence
+		 WHERE address = ANY($1) AND connections > 0 AND updated_at >= $2`,
+		addresses, time.Now().Add(-p.conf.PresenceTTL),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch presence: %w", err)
+	}
+	defer rows.Close()
+
+	online := make(map[string]bool, len(addresses))
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			return nil, fmt.Errorf("failed to scan presence: %w", err)
+		}
+		online[addr] = true
+	}
+	return online, rows.Err()
+}
+
+func (p *Postgres) Subscribe(room, address string) error {
+	_, err := p.pool.Exec(
+		p.ctx,
+		`INSERT INTO hub_subscriptions (instance_id, room, address, connections) VALUES ($1, $2, $3, 1)
+		 ON CONFLICT (instance_id, room, address)
+		 DO UPDATE SET connections = hub_subscriptions.connections + 1, updated_at = NOW()`,
+		p.id, room, address,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record subscription: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Unsubscribe(room, address string) error {
+	_, err := p.pool.Exec(
+		p.ctx,
+		`UPDATE hub_subscriptions SET connections = connections - 1, updated_at = NOW()
+		 WHERE instance_id = $1 AND room = $2 AND address = $3`,
+		p.id, room, address,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record subscription: %w", err)
+	}
+	_, err = p.pool.Exec(
+		p.ctx,
+		`DELETE FROM hub_subscriptions WHERE instance_id = $1 AND room = $2 AND address = $3 AND connections <= 0`,
+		p.id, room, address,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record subscription: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Subscribed(room string, addresses []string) (map[string]bool, error) {
+	rows, err := p.pool.Query(
+		p.ctx,
+		`SELECT DISTINCT address FROM hub_subscriptions
+		 WHERE room = $1 AND address = ANY($2) AND connections > 0 AND updated_at >= $3`,
+		room, addresses, time.Now().Add(-p.conf.PresenceTTL),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subscribed := make(map[string]bool, len(addresses))
+	for rows.Next() {
+		var addr string
+		if err := rows.Scan(&addr); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+		subscribed[addr] = true
+	}
+	return subscribed, rows.Err()
+}
+
+func (p *Postgres) Close() {
+	p.cancel()
+	if _, err := p.pool.Exec(context.Background(), `DELETE FROM hub_presence WHERE instance_id = $1`, p.id); err != nil {
+		log.Error().Err(err).Str("instance", p.id).Msg("Failed to clear hub presence")
+	}
+	if _, err := p.pool.Exec(context.Background(), `DELETE FROM hub_subscriptions WHERE instance_id = $1`, p.id); err != nil {
+		log.Error().Err(err).Str("instance", p.id).Msg("Failed to clear hub subscriptions")
+	}
+}
@@ -0,0 +1,101 @@
+package broker
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	start := time.Now()
+	c := cursor{last: 10, gaps: make(map[int64]time.Time)}
+
+	// Events 11 and 12 are committed after 13 and 14.
+	for _, id := range []int64{13, 14} {
+		if !c.advance(id, start) {
+			t.Fatalf("event %d not delivered", id)
+		}
+	}
+	if c.last != 14 {
+		t.Fatalf("last = %d, want 14", c.last)
+	}
+	pending := c.pending(start, time.Second)
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+	if want := []int64{11, 12}; !reflect.DeepEqual(pending, want) {
+		t.Fatalf("pending = %v, want %v", pending, want)
+	}
+
+	// Reading the range again only delivers the gap that showed up.
+	for _, tt := range []struct {
+		id   int64
+		want bool
+	}{{10, false}, {12, true}, {12, false}, {13, false}, {14, false}, {15, true}} {
+		if got := c.advance(tt.id, start); got != tt.want {
+			t.Errorf("advance(%d) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+
+	// A gap that never commits is given up after the timeout.
+	if pending := c.pending(start.Add(500*time.Millisecond), time.Second); !reflect.DeepEqual(pending, []int64{11}) {
+		t.Fatalf("pending before the timeout = %v, want [11]", pending)
+	}
+	if pending := c.pending(start.Add(2*time.Second), time.Second); len(pending) != 0 {
+		t.Fatalf("pending after the timeout = %v, want none", pending)
+	}
+	if c.advance(11, start.Add(3*time.Second)) {
+		t.Error("event committed after its gap timed out was delivered")
+	}
+}
+
+func TestCursorBoundsGaps(t *testing.T) {
+	c := cursor{gaps: make(map[int64]time.Time)}
+	c.advance(maxGap*3, time.Now())
+	if len(c.gaps) != maxGap {
+		t.Fatalf("tracked %d gaps, want %d", len(c.gaps), maxGap)
+	}
+	if _, ok := c.gaps[maxGap*3-1]; !ok {
+		t.Error("the closest skipped ID is not tracked")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	l, err := NewLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Event
+	l.Listen(func(e Event) { got = append(got, e) })
+	if err := l.Publish(Event{Kind: KindUser, Address: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Address != "a" {
+		t.Fatalf("delivered %+v, want the published event", got)
+	}
+
+	if n, _ := l.Join("a"); n != 1 {
+		t.Errorf("Join = %d, want 1", n)
+	}
+	if n, _ := l.Join("a"); n != 2 {
+		t.Errorf("Join = %d, want 2", n)
+	}
+	if n, _ := l.Leave("a"); n != 1 {
+		t.Errorf("Leave = %d, want 1", n)
+	}
+	if online, _ := l.Online([]string{"a", "b"}); !online["a"] || online["b"] {
+		t.Errorf("Online = %v, want only a", online)
+	}
+
+	if err := l.Subscribe("1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if subscribed, _ := l.Subscribed("1", []string{"a", "b"}); !subscribed["a"] || subscribed["b"] {
+		t.Errorf("Subscribed = %v, want only a", subscribed)
+	}
+	if err := l.Unsubscribe("1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if subscribed, _ := l.Subscribed("1", []string{"a"}); subscribed["a"] {
+		t.Error("a is still subscribed after unsubscribing")
+	}
+}
@@ -0,0 +1,428 @@
+package ceremony
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	KindKeygen  = "keygen"
+	KindSigning = "signing"
+	KindReshare = "reshare"
+)
+
+const (
+	EventStarted   = "started"
+	EventRound     = "round"
+	EventCompleted = "completed"
+	EventAborted   = "aborted"
+)
+
+var (
+	ErrSessionNotFound  = errors.New("ceremony session not found")
+	ErrNotParticipant   = errors.New("sender is not a participant of the session")
+	ErrWrongRound       = errors.New("message is not for the current round")
+	ErrInvalidRecipient = errors.New("recipient is not another participant of the session")
+	ErrAlreadyReported  = errors.New("participant has already reported a result")
+	ErrTooFewMembers    = errors.New("not enough participants for the ceremony")
+	ErrSenderMismatch   = errors.New("envelope sender does not match the authenticated address")
+	ErrStaleSequence    = errors.New("envelope sequence number is not increasing")
+)
+
+// Relay delivers messages to connected participants. Ceremony traffic is only
+// useful while the session runs, so it is not kept for offline participants.
+type Relay interface {
+	NotifyUserOnline(address string, message interface{}) bool
+}
+
+// Callbacks are invoked after a session changes state, outside of the manager lock.
+type Callbacks struct {
+	OnRound    func(s types.CeremonySession)
+	OnComplete func(s types.CeremonySession)
+	OnAbort    func(s types.CeremonySession)
+}
+
+type session struct {
+	info      types.CeremonySession
+	members   map[string]bool
+	covered   map[string]map[string]bool
+	results   map[string]string
+	sequences map[string]uint64
+	timer     *time.Timer
+	callbacks Callbacks
+}
+
+// Manager coordinates running ceremony sessions. It only routes messages and
+// tracks rounds; the protocol itself runs on the participants.
+type Manager struct {
+	mu           sync.Mutex
+	relay        Relay
+	roundTimeout time.Duration
+	sessions     map[string]*session
+}
+
+// NewManager creates a Manager that aborts sessions whose round does not complete within roundTimeout.
+func NewManager(relay Relay, roundTimeout time.Duration) *Manager {
+	return &Manager{
+		relay:        relay,
+		roundTimeout: roundTimeout,
+		sessions:     make(map[string]*session),
+	}
+}
+
+// Prepare returns the session Start would open for spec, with a fresh ID and
+// the participants sorted and deduplicated, so it can be stored before it
+// starts. The kind, organization, initiator, subject, transaction,
+// participants and instance, as well as the old and new participants of a
+// reshare, are taken from spec.
+func (m *Manager) Prepare(spec types.CeremonySession) (types.CeremonySession, error) {
+	members := make(map[string]bool, len(spec.Participants))
+	for _, p := range spec.Participants {
+		members[p] = true
+	}
+	// A session needs someone to run it; an empty participant set is refused.
+	if len(members) == 0 {
+		return types.CeremonySession{}, ErrTooFewMembers
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+
+	sorted := make([]string, 0, len(members))
+	for p := range members {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	now := time.Now().UTC()
+	return types.CeremonySession{
+		ID:              id,
+		Kind:            spec.Kind,
+		OrganizationID:  spec.OrganizationID,
+		TransactionID:   spec.TransactionID,
+		Initiator:       spec.Initiator,
+		Participants:    sorted,
+		OldParticipants: spec.OldParticipants,
+		NewParticipants: spec.NewParticipants,
+		Subject:         spec.Subject,
+		Status:          types.CeremonyRunning,
+		Round:           1,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Instance:        spec.Instance,
+	}, nil
+}
+
+// Start opens a session at round 1 and notifies every participant. A session
+// returned by Prepare is opened as it is, any other spec is prepared first.
+func (m *Manager) Start(spec types.CeremonySession, cb Callbacks) (types.CeremonySession, error) {
+	info := spec
+	if info.ID == "" {
+		var err error
+		if info, err = m.Prepare(spec); err != nil {
+			return types.CeremonySession{}, err
+		}
+	}
+
+	members := make(map[string]bool, len(info.Participants))
+	for _, p := range info.Participants {
+		members[p] = true
+	}
+	if len(members) == 0 {
+		return types.CeremonySession{}, ErrTooFewMembers
+	}
+
+	id := info.ID
+	s := &session{
+		info:      info,
+		members:   members,
+		covered:   make(map[string]map[string]bool),
+		results:   make(map[string]string),
+		sequences: make(map[string]uint64),
+		callbacks: cb,
+	}
+
+	m.mu.Lock()
+	m.sessions[id] = s
+	s.timer = time.AfterFunc(m.roundTimeout, func() { m.timeout(id, 1) })
+	m.mu.Unlock()
+
+	m.notify(info, EventStarted)
+	return info, nil
+}
+
+// Get returns a snapshot of a running session.
+func (m *Manager) Get(id string) (types.CeremonySession, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return types.CeremonySession{}, false
+	}
+	return s.info, true
+}
+
+// HandleMessage validates and relays a round message from a participant.
+func (m *Manager) HandleMessage(from string, msg types.CeremonyMessage) error {
+	msg.From = from
+	return m.route(from, msg.SessionID, msg.Round, msg.To, nil, msg)
+}
+
+// HandleEnvelope validates the routing header of an encrypted point-to-point
+// message and relays it unchanged to its recipient. Sequence numbers must
+// strictly increase per sender and recipient within a session.
+func (m *Manager) HandleEnvelope(from string, env types.Envelope) error {
+	if env.From != from {
+		return ErrSenderMismatch
+	}
+	if env.To == "" {
+		return ErrInvalidRecipient
+	}
+
+	checkSequence := func(s *session) error {
+		key := env.From + "\x00" + env.To
+		if env.Sequence <= s.sequences[key] {
+			return fmt.Errorf("%w: got %d, last was %d", ErrStaleSequence, env.Sequence, s.sequences[key])
+		}
+		s.sequences[key] = env.Sequence
+		return nil
+	}
+	return m.route(from, env.SessionID, env.Round, env.To, checkSequence, env)
+}
+
+// route checks the routing metadata of a message, records which recipients the
+// sender has covered in the round and relays the message. An empty recipient
+// broadcasts to every other participant.
+func (m *Manager) route(from, sessionID string, round int, to string, accept func(s *session) error, message interface{}) error {
+	m.mu.Lock()
+	s, ok := m.sessions[sessionID]
+	if !ok {
+		m.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	if !s.members[from] {
+		m.mu.Unlock()
+		return ErrNotParticipant
+	}
+	if round != s.info.Round {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: got %d, current round is %d", ErrWrongRound, round, s.info.Round)
+	}
+	if to != "" && (to == from || !s.members[to]) {
+		m.mu.Unlock()
+		return ErrInvalidRecipient
+	}
+	if accept != nil {
+		if err := accept(s); err != nil {
+			m.mu.Unlock()
+			return err
+		}
+	}
+
+	var recipients []string
+	if to == "" {
+		for _, p := range s.info.Participants {
+			if p != from {
+				recipients = append(recipients, p)
+			}
+		}
+	} else {
+		recipients = []string{to}
+	}
+
+	if s.covered[from] == nil {
+		s.covered[from] = make(map[string]bool)
+	}
+	for _, r := range recipients {
+		s.covered[from][r] = true
+	}
+
+	advanced, info := m.advance(s)
+	m.mu.Unlock()
+
+	for _, r := range recipients {
+		m.relay.NotifyUserOnline(r, message)
+	}
+	if advanced {
+		m.notify(info, EventRound)
+		if s.callbacks.OnRound != nil {
+			s.callbacks.OnRound(info)
+		}
+	}
+	return nil
+}
+
+// HandleResult records the outcome reported by a participant. The session
+// completes once every participant has reported the same result. A single
+// participant, e.g. of a 1-of-1 organization, has nobody to message and
+// completes its session by reporting the result alone.
+func (m *Manager) HandleResult(from string, res types.CeremonyResult) error {
+	m.mu.Lock()
+	s, ok := m.sessions[res.SessionID]
+	if !ok {
+		m.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	if !s.members[from] {
+		m.mu.Unlock()
+		return ErrNotParticipant
+	}
+	if _, reported := s.results[from]; reported {
+		m.mu.Unlock()
+		return ErrAlreadyReported
+	}
+	s.results[from] = res.Result
+
+	if len(s.results) < len(s.members) {
+		advanced, info := m.advance(s)
+		m.mu.Unlock()
+		if advanced {
+			m.notify(info, EventRound)
+			if s.callbacks.OnRound != nil {
+				s.callbacks.OnRound(info)
+			}
+		}
+		return nil
+	}
+
+	result, dissenters := majority(s.results)
+	if len(dissenters) > 0 {
+		info := m.finish(s, types.CeremonyAborted, "", dissenters, "participants reported different results")
+		m.mu.Unlock()
+		m.notify(info, EventAborted)
+		if s.callbacks.OnAbort != nil {
+			s.callbacks.OnAbort(info)
+		}
+		return nil
+	}
+
+	info := m.finish(s, types.CeremonyCompleted, result, nil, "")
+	m.mu.Unlock()
+	m.notify(info, EventCompleted)
+	if s.callbacks.OnComplete != nil {
+		s.callbacks.OnComplete(info)
+	}
+	return nil
+}
+
+// Abort stops a running session and blames the given participants.
+func (m *Manager) Abort(id, reason string, blamed []string) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	info := m.finish(s, types.CeremonyAborted, "", blamed, reason)
+	m.mu.Unlock()
+
+	m.notify(info, EventAborted)
+	if s.callbacks.OnAbort != nil {
+		s.callbacks.OnAbort(info)
+	}
+}
+
+// advance moves the session to the next round once every participant is done
+// with the current one. It must be called with the lock held.
+func (m *Manager) advance(s *session) (bool, types.CeremonySession) {
+	if len(m.pending(s)) > 0 {
+		return false, s.info
+	}
+
+	s.info.Round++
+	s.info.UpdatedAt = time.Now().UTC()
+	s.covered = make(map[string]map[string]bool)
+	s.timer.Stop()
+	id, round := s.info.ID, s.info.Round
+	s.timer = time.AfterFunc(m.roundTimeout, func() { m.timeout(id, round) })
+	return true, s.info
+}
+
+// pending lists participants that have neither messaged every other participant
+// in the current round nor reported a result. A lone participant has no one
+// to message, so it is never pending. It must be called with the lock held.
+func (m *Manager) pending(s *session) []string {
+	var pending []string
+	for _, p := range s.info.Participants {
+		if _, done := s.results[p]; done {
+			continue
+		}
+		if len(s.covered[p]) < len(s.members)-1 {
+			pending = append(pending, p)
+		}
+	}
+	return pending
+}
+
+func (m *Manager) timeout(id string, round int) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	if !ok || s.info.Round != round {
+		m.mu.Unlock()
+		return
+	}
+	info := m.finish(s, types.CeremonyAborted, "", m.pending(s), fmt.Sprintf("round %d timed out", round))
+	m.mu.Unlock()
+
+	m.notify(info, EventAborted)
+	if s.callbacks.OnAbort != nil {
+		s.callbacks.OnAbort(info)
+	}
+}
+
+// finish ends a session and forgets it. It must be called with the lock held.
+func (m *Manager) finish(s *session, status types.CeremonyStatus, result string, blamed []string, reason string) types.CeremonySession {
+	s.timer.Stop()
+	s.info.Status = status
+	s.info.Result = result
+	s.info.Blamed = blamed
+	s.info.Reason = reason
+	s.info.UpdatedAt = time.Now().UTC()
+	delete(m.sessions, s.info.ID)
+	return s.info
+}
+
+func (m *Manager) notify(info types.CeremonySession, event string) {
+	for _, p := range info.Participants {
+		m.relay.NotifyUserOnline(p, types.CeremonyEvent{Event: event, Session: info})
+	}
+}
+
+// majority returns the most reported result and the participants that reported something else.
+func majority(results map[string]string) (string, []string) {
+	counts := make(map[string]int)
+	for _, r := range results {
+		counts[r]++
+	}
+	var best string
+	for r, n := range counts {
+		if n > counts[best] || (n == counts[best] && r < best) {
+			best = r
+		}
+	}
+
+	var dissenters []string
+	for p, r := range results {
+		if r != best {
+			dissenters = append(dissenters, p)
+		}
+	}
+	sort.Strings(dissenters)
+	return best, dissenters
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
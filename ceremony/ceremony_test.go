@@ -0,0 +1,323 @@
+package ceremony
+
+import (
+	"errors"
+	"mpc-backend/types"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeRelay struct {
+	mu       sync.Mutex
+	messages map[string][]interface{}
+}
+
+func (r *fakeRelay) NotifyUserOnline(address string, message interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.messages == nil {
+		r.messages = make(map[string][]interface{})
+	}
+	r.messages[address] = append(r.messages[address], message)
+	return true
+}
+
+// events returns the ceremony events an address received, in order.
+func (r *fakeRelay) events(address string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []string
+	for _, m := range r.messages[address] {
+		if e, ok := m.(types.CeremonyEvent); ok {
+			events = append(events, e.Event)
+		}
+	}
+	return events
+}
+
+// outcomes collects the sessions passed to the callbacks.
+type outcomes struct {
+	rounds   chan types.CeremonySession
+	complete chan types.CeremonySession
+	abort    chan types.CeremonySession
+}
+
+func newOutcomes() *outcomes {
+	return &outcomes{
+		rounds:   make(chan types.CeremonySession, 16),
+		complete: make(chan types.CeremonySession, 1),
+		abort:    make(chan types.CeremonySession, 1),
+	}
+}
+
+func (o *outcomes) callbacks() Callbacks {
+	return Callbacks{
+		OnRound:    func(s types.CeremonySession) { o.rounds <- s },
+		OnComplete: func(s types.CeremonySession) { o.complete <- s },
+		OnAbort:    func(s types.CeremonySession) { o.abort <- s },
+	}
+}
+
+func wait(t *testing.T, c chan types.CeremonySession, what string) types.CeremonySession {
+	t.Helper()
+	select {
+	case s := <-c:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no %s", what)
+		return types.CeremonySession{}
+	}
+}
+
+func start(t *testing.T, m *Manager, o *outcomes, participants ...string) types.CeremonySession {
+	t.Helper()
+	s, err := m.Start(types.CeremonySession{Kind: KindKeygen, OrganizationID: 1, Participants: participants}, o.callbacks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestStart(t *testing.T) {
+	relay := &fakeRelay{}
+	m := NewManager(relay, time.Minute)
+	if _, err := m.Start(types.CeremonySession{Kind: KindKeygen}, Callbacks{}); !errors.Is(err, ErrTooFewMembers) {
+		t.Errorf("no participants: got %v, want ErrTooFewMembers", err)
+	}
+
+	s := start(t, m, newOutcomes(), "c", "a", "b", "a")
+	if !reflect.DeepEqual(s.Participants, []string{"a", "b", "c"}) || s.Round != 1 || s.Status != types.CeremonyRunning {
+		t.Errorf("started %+v, want round 1 of a, b and c", s)
+	}
+	for _, p := range s.Participants {
+		if got := relay.events(p); !reflect.DeepEqual(got, []string{EventStarted}) {
+			t.Errorf("%s received %v, want the start event", p, got)
+		}
+	}
+}
+
+func TestStartPrepared(t *testing.T) {
+	relay := &fakeRelay{}
+	m := NewManager(relay, time.Minute)
+	if _, err := m.Prepare(types.CeremonySession{Kind: KindKeygen}); !errors.Is(err, ErrTooFewMembers) {
+		t.Errorf("no participants: got %v, want ErrTooFewMembers", err)
+	}
+
+	prepared, err := m.Prepare(types.CeremonySession{Kind: KindKeygen, OrganizationID: 1, Participants: []string{"b", "a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prepared.ID == "" || !reflect.DeepEqual(prepared.Participants, []string{"a", "b"}) || prepared.Round != 1 || prepared.Status != types.CeremonyRunning {
+		t.Fatalf("prepared %+v, want round 1 of a and b", prepared)
+	}
+	if _, running := m.Get(prepared.ID); running {
+		t.Fatal("prepared session is running before it was started")
+	}
+
+	s, err := m.Start(prepared, Callbacks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s, prepared) {
+		t.Errorf("started %+v, want the prepared %+v", s, prepared)
+	}
+	if _, running := m.Get(prepared.ID); !running {
+		t.Error("prepared session is not running once started")
+	}
+	if got := relay.events("a"); !reflect.DeepEqual(got, []string{EventStarted}) {
+		t.Errorf("a received %v, want the start event", got)
+	}
+}
+
+func TestRounds(t *testing.T) {
+	relay := &fakeRelay{}
+	m := NewManager(relay, time.Minute)
+	o := newOutcomes()
+	s := start(t, m, o, "a", "b", "c")
+
+	msg := func(round int, to string) types.CeremonyMessage {
+		return types.CeremonyMessage{SessionID: s.ID, Round: round, To: to}
+	}
+	tests := []struct {
+		name string
+		from string
+		msg  types.CeremonyMessage
+		err  error
+	}{
+		{"unknown session", "a", types.CeremonyMessage{SessionID: "nope", Round: 1}, ErrSessionNotFound},
+		{"outsider", "d", msg(1, ""), ErrNotParticipant},
+		{"future round", "a", msg(2, ""), ErrWrongRound},
+		{"to itself", "a", msg(1, "a"), ErrInvalidRecipient},
+		{"to an outsider", "a", msg(1, "d"), ErrInvalidRecipient},
+		{"broadcast", "a", msg(1, ""), nil},
+		{"direct", "b", msg(1, "a"), nil},
+		{"second direct", "b", msg(1, "c"), nil},
+	}
+	for _, tt := range tests {
+		if err := m.HandleMessage(tt.from, tt.msg); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+	if info, _ := m.Get(s.ID); info.Round != 1 {
+		t.Fatalf("advanced to round %d before c was done", info.Round)
+	}
+
+	if err := m.HandleMessage("c", msg(1, "")); err != nil {
+		t.Fatal(err)
+	}
+	if round := wait(t, o.rounds, "round callback").Round; round != 2 {
+		t.Errorf("advanced to round %d, want 2", round)
+	}
+	if err := m.HandleMessage("a", msg(1, "")); !errors.Is(err, ErrWrongRound) {
+		t.Errorf("late message: got %v, want ErrWrongRound", err)
+	}
+	if got := relay.events("b"); !reflect.DeepEqual(got, []string{EventStarted, EventRound}) {
+		t.Errorf("b received %v, want the start and round events", got)
+	}
+}
+
+func TestEnvelopeSequence(t *testing.T) {
+	m := NewManager(&fakeRelay{}, time.Minute)
+	s := start(t, m, newOutcomes(), "a", "b", "c")
+
+	env := func(from, to string, sequence uint64) types.Envelope {
+		return types.Envelope{SessionID: s.ID, From: from, To: to, Round: 1, Sequence: sequence}
+	}
+	tests := []struct {
+		name   string
+		sender string
+		env    types.Envelope
+		err    error
+	}{
+		{"spoofed sender", "b", env("a", "b", 1), ErrSenderMismatch},
+		{"broadcast", "a", env("a", "", 1), ErrInvalidRecipient},
+		{"zero sequence", "a", env("a", "b", 0), ErrStaleSequence},
+		{"first", "a", env("a", "b", 1), nil},
+		{"replayed", "a", env("a", "b", 1), ErrStaleSequence},
+		{"other pair", "a", env("a", "c", 1), nil},
+		{"gap", "a", env("a", "b", 5), nil},
+		{"older", "a", env("a", "b", 4), ErrStaleSequence},
+	}
+	for _, tt := range tests {
+		if err := m.HandleEnvelope(tt.sender, tt.env); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	relay := &fakeRelay{}
+	m := NewManager(relay, 20*time.Millisecond)
+	o := newOutcomes()
+	s := start(t, m, o, "a", "b", "c")
+
+	if err := m.HandleMessage("a", types.CeremonyMessage{SessionID: s.ID, Round: 1}); err != nil {
+		t.Fatal(err)
+	}
+	aborted := wait(t, o.abort, "abort after the round timed out")
+	if aborted.Status != types.CeremonyAborted || aborted.Reason != "round 1 timed out" {
+		t.Errorf("aborted %+v, want round 1 timed out", aborted)
+	}
+	if !reflect.DeepEqual(aborted.Blamed, []string{"b", "c"}) {
+		t.Errorf("blamed %v, want the participants that did not send", aborted.Blamed)
+	}
+	if _, ok := m.Get(s.ID); ok {
+		t.Error("aborted session is still running")
+	}
+	if got := relay.events("a"); !reflect.DeepEqual(got, []string{EventStarted, EventAborted}) {
+		t.Errorf("a received %v, want the start and abort events", got)
+	}
+}
+
+func TestResults(t *testing.T) {
+	tests := []struct {
+		name    string
+		results map[string]string
+		status  types.CeremonyStatus
+		result  string
+		blamed  []string
+	}{
+		{"agreement", map[string]string{"a": "key", "b": "key", "c": "key"}, types.CeremonyCompleted, "key", nil},
+		{"dissent", map[string]string{"a": "key", "b": "key", "c": "other"}, types.CeremonyAborted, "", []string{"c"}},
+		{"no majority", map[string]string{"a": "x", "b": "y", "c": "z"}, types.CeremonyAborted, "", []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		m := NewManager(&fakeRelay{}, time.Minute)
+		o := newOutcomes()
+		s := start(t, m, o, "a", "b", "c")
+		for _, p := range []string{"a", "b", "c"} {
+			if err := m.HandleResult(p, types.CeremonyResult{SessionID: s.ID, Result: tt.results[p]}); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+
+		done := o.complete
+		if tt.status == types.CeremonyAborted {
+			done = o.abort
+		}
+		info := wait(t, done, tt.name)
+		if info.Status != tt.status || info.Result != tt.result || !reflect.DeepEqual(info.Blamed, tt.blamed) {
+			t.Errorf("%s: finished %s with %q blaming %v, want %s with %q blaming %v",
+				tt.name, info.Status, info.Result, info.Blamed, tt.status, tt.result, tt.blamed)
+		}
+	}
+}
+
+func TestResultErrors(t *testing.T) {
+	m := NewManager(&fakeRelay{}, time.Minute)
+	o := newOutcomes()
+	s := start(t, m, o, "a", "b")
+
+	tests := []struct {
+		name string
+		from string
+		res  types.CeremonyResult
+		err  error
+	}{
+		{"unknown session", "a", types.CeremonyResult{SessionID: "nope"}, ErrSessionNotFound},
+		{"outsider", "c", types.CeremonyResult{SessionID: s.ID}, ErrNotParticipant},
+		{"first", "a", types.CeremonyResult{SessionID: s.ID, Result: "key"}, nil},
+		{"twice", "a", types.CeremonyResult{SessionID: s.ID, Result: "key"}, ErrAlreadyReported},
+	}
+	for _, tt := range tests {
+		if err := m.HandleResult(tt.from, tt.res); !errors.Is(err, tt.err) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestMajority(t *testing.T) {
+	tests := []struct {
+		results    map[string]string
+		want       string
+		dissenters []string
+	}{
+		{map[string]string{"a": "x"}, "x", nil},
+		{map[string]string{"a": "x", "b": "y", "c": "y"}, "y", []string{"a"}},
+		{map[string]string{"a": "y", "b": "x"}, "x", []string{"a"}},
+	}
+	for _, tt := range tests {
+		got, dissenters := majority(tt.results)
+		if got != tt.want || !reflect.DeepEqual(dissenters, tt.dissenters) {
+			t.Errorf("majority(%v) = %q, %v, want %q, %v", tt.results, got, dissenters, tt.want, tt.dissenters)
+		}
+	}
+}
+
+func TestSingleParticipant(t *testing.T) {
+	m := NewManager(&fakeRelay{}, time.Minute)
+	o := newOutcomes()
+	s := start(t, m, o, "a")
+
+	if err := m.HandleMessage("a", types.CeremonyMessage{SessionID: s.ID, Round: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.HandleResult("a", types.CeremonyResult{SessionID: s.ID, Result: "key"}); err != nil {
+		t.Fatal(err)
+	}
+	if info := wait(t, o.complete, "completion"); info.Result != "key" {
+		t.Errorf("completed with %q, want the reported result", info.Result)
+	}
+}
@@ -27,6 +27,46 @@ func init() {
 	// Bind the flags to the configuration
 	_ = viper.BindPFlag("server.host", serveCmd.Flags().Lookup(hostFlag))
 	_ = viper.BindPFlag("server.port", rootCmd.Flags().Lookup(portFlag))
+
+	viper.SetDefault("txconf.ttl", "24h")
+	viper.SetDefault("txconf.expiryinterval", "1m")
+	viper.SetDefault("authconf.challengettl", "5m")
+	viper.SetDefault("authconf.sessionttl", "1h")
+	viper.SetDefault("ceremonyconf.roundtimeout", "1m")
+	viper.SetDefault("ceremonyconf.signingattempts", 3)
+	viper.SetDefault("ceremonyconf.refreshinterval", "720h")
+	viper.SetDefault("ceremonyconf.resharecheckinterval", "1m")
+	viper.SetDefault("queueconf.retention", "168h")
+	viper.SetDefault("queueconf.pruneinterval", "1h")
+	viper.SetDefault("hubconf.sendqueuesize", 256)
+	viper.SetDefault("hubconf.writetimeout", "10s")
+	viper.SetDefault("hubconf.slowconsumerpolicy", "drop-oldest")
+	viper.SetDefault("hubconf.pinginterval", "30s")
+	viper.SetDefault("hubconf.pongtimeout", "60s")
+	viper.SetDefault("hubconf.maxmessagesize", 1<<20)
+	viper.SetDefault("brokerconf.backend", "local")
+	viper.SetDefault("brokerconf.heartbeatinterval", "15s")
+	viper.SetDefault("brokerconf.presencettl", "45s")
+	viper.SetDefault("brokerconf.eventretention", "1h")
+	viper.SetDefault("brokerconf.gaptimeout", "30s")
+	viper.SetDefault("eventconf.retention", "24h")
+	viper.SetDefault("eventconf.pruneinterval", "1h")
+	viper.SetDefault("webhookconf.pollinterval", "2s")
+	viper.SetDefault("webhookconf.timeout", "10s")
+	viper.SetDefault("webhookconf.batchsize", 20)
+	viper.SetDefault("webhookconf.maxattempts", 10)
+	viper.SetDefault("webhookconf.backoffbase", "10s")
+	viper.SetDefault("webhookconf.backoffmax", "1h")
+	viper.SetDefault("outboxconf.pollinterval", "1s")
+	viper.SetDefault("outboxconf.batchsize", 50)
+	viper.SetDefault("outboxconf.lease", "30s")
+	viper.SetDefault("outboxconf.maxattempts", 20)
+	viper.SetDefault("outboxconf.retryinterval", "5s")
+	viper.SetDefault("outboxconf.retention", "168h")
+	viper.SetDefault("outboxconf.pruneinterval", "1h")
+	viper.SetDefault("inviteconf.ttl", "168h")
+	viper.SetDefault("inviteconf.expiryinterval", "1m")
+	viper.SetDefault("keyconf.bitcoinnetwork", "mainnet")
 }
 
 func run(_ *cobra.Command, _ []string) {
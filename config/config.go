@@ -1,10 +1,24 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type Configuration struct {
-	DbConfig   DbConfig
-	ServerConf ServerConf
+	DbConfig     DbConfig
+	ServerConf   ServerConf
+	TxConf       TxConf
+	AuthConf     AuthConf
+	CeremonyConf CeremonyConf
+	QueueConf    QueueConf
+	HubConf      HubConf
+	BrokerConf   BrokerConf
+	EventConf    EventConf
+	WebhookConf  WebhookConf
+	OutboxConf   OutboxConf
+	InviteConf   InviteConf
+	KeyConf      KeyConf
 }
 
 type DbConfig struct {
@@ -21,6 +35,123 @@ type ServerConf struct {
 	Port int
 }
 
+type TxConf struct {
+	// TTL is how long a transaction stays pending before it expires.
+	TTL time.Duration
+	// ExpiryInterval is how often pending transactions are checked for expiry.
+	ExpiryInterval time.Duration
+}
+
+type AuthConf struct {
+	// ChallengeTTL is how long a login nonce can be signed before it expires.
+	ChallengeTTL time.Duration
+	// SessionTTL is how long a session token stays valid after login.
+	SessionTTL time.Duration
+}
+
+type CeremonyConf struct {
+	// RoundTimeout is how long participants have to finish a ceremony round before the session is aborted.
+	RoundTimeout time.Duration
+	// SigningAttempts is how many signer sets are tried before a stalled signing is given up.
+	SigningAttempts int
+	// RefreshInterval is how long key shares are used before they are reshared for proactive
+	// security. Zero disables periodic refreshes.
+	RefreshInterval time.Duration
+	// ReshareCheckInterval is how often organizations are checked for a due reshare.
+	ReshareCheckInterval time.Duration
+}
+
+type QueueConf struct {
+	// Retention is how long messages for offline addresses are kept before they are pruned.
+	Retention time.Duration
+	// PruneInterval is how often expired queued messages are removed.
+	PruneInterval time.Duration
+}
+
+type HubConf struct {
+	// SendQueueSize is how many outbound messages each connection buffers.
+	SendQueueSize int
+	// WriteTimeout bounds a single write to a socket.
+	WriteTimeout time.Duration
+	// SlowConsumerPolicy is "drop-oldest" or "disconnect" and applies when a send queue is full.
+	SlowConsumerPolicy string
+	// PingInterval is how often each connection is pinged.
+	PingInterval time.Duration
+	// PongTimeout is how long a connection may stay silent before it is considered dead.
+	// It must be longer than PingInterval.
+	PongTimeout time.Duration
+	// MaxMessageSize is the largest inbound frame in bytes.
+	MaxMessageSize int64
+}
+
+type BrokerConf struct {
+	// Backend is "local" for a single instance or "postgres" to share hub events
+	// between instances through LISTEN/NOTIFY.
+	Backend string
+	// HeartbeatInterval is how often an instance refreshes its presence rows.
+	HeartbeatInterval time.Duration
+	// PresenceTTL is how long presence rows count after the last heartbeat.
+	// It must be longer than HeartbeatInterval.
+	PresenceTTL time.Duration
+	// EventRetention is how long published hub events are kept.
+	EventRetention time.Duration
+	// GapTimeout is how long a listener waits for an event ID that was skipped
+	// by a later one, since its publisher may not have committed yet.
+	GapTimeout time.Duration
+}
+
+type EventConf struct {
+	// Retention is how long organization events are kept for clients resuming after a disconnect.
+	Retention time.Duration
+	// PruneInterval is how often expired organization events are deleted.
+	PruneInterval time.Duration
+}
+
+type WebhookConf struct {
+	// PollInterval is how often due webhook deliveries are picked up.
+	PollInterval time.Duration
+	// Timeout bounds a single delivery request.
+	Timeout time.Duration
+	// BatchSize is how many deliveries are picked up per poll.
+	BatchSize int
+	// MaxAttempts is how often a delivery is tried before it is marked failed.
+	MaxAttempts int
+	// BackoffBase is the delay after the first failed attempt, doubled after each further one.
+	BackoffBase time.Duration
+	// BackoffMax caps the delay between attempts.
+	BackoffMax time.Duration
+}
+
+type OutboxConf struct {
+	// PollInterval is how often the outbox is checked for events written by other instances.
+	PollInterval time.Duration
+	// BatchSize is how many events are claimed at once.
+	BatchSize int
+	// Lease is how long a claimed event is left alone by other instances before it is claimed again.
+	Lease time.Duration
+	// MaxAttempts is how often an event is dispatched before it is marked failed.
+	MaxAttempts int
+	// RetryInterval is the delay after a failed attempt, multiplied by the number of attempts so far.
+	RetryInterval time.Duration
+	// Retention is how long dispatched and failed events are kept.
+	Retention time.Duration
+	// PruneInterval is how often old events are deleted.
+	PruneInterval time.Duration
+}
+
+type InviteConf struct {
+	// TTL is how long an invitation can be answered before it expires.
+	TTL time.Duration
+	// ExpiryInterval is how often pending invitations are checked for expiry.
+	ExpiryInterval time.Duration
+}
+
+type KeyConf struct {
+	// BitcoinNetwork is "mainnet", "testnet", "signet" or "regtest" and selects the
+	// prefix of the Bitcoin addresses derived from organization keys.
+	BitcoinNetwork string
+}
+
 func (c *DbConfig) ConnectionString(driver string) string {
 	connStr := fmt.Sprintf("%s://%s:%s@%s:%d/%s", driver, c.Username, c.Password, c.Host, c.Port, c.Database)
 	if c.SSLMode != nil {
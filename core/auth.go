@@ -0,0 +1,88 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrChallengeNotFound = errors.New("challenge not found or expired")
+	ErrSessionNotFound   = errors.New("session not found or expired")
+)
+
+// CreateChallenge stores a login challenge for an address.
+func (c *CRUD) CreateChallenge(address, nonce, message string, expiresAt time.Time) error {
+	_, err := c.Connection.Exec(
+		context.Background(),
+		"INSERT INTO auth_challenges (nonce, address, message, expires_at) VALUES ($1, $2, $3, $4)",
+		nonce, address, message, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store challenge: %w", err)
+	}
+	return nil
+}
+
+// ConsumeChallenge deletes a challenge so it can only be used once and returns the message that was issued.
+func (c *CRUD) ConsumeChallenge(address, nonce string) (string, error) {
+	var message string
+	err := c.Connection.QueryRow(
+		context.Background(),
+		`DELETE FROM auth_challenges
+		 WHERE nonce = $1 AND address = $2 AND expires_at > NOW()
+		 RETURNING message`,
+		nonce, address,
+	).Scan(&message)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrChallengeNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to consume challenge: %w", err)
+	}
+	return message, nil
+}
+
+// CreateSession stores a session for an address, identified by the hash of its token.
+func (c *CRUD) CreateSession(tokenHash, address string, expiresAt time.Time) error {
+	_, err := c.Connection.Exec(
+		context.Background(),
+		"INSERT INTO sessions (token_hash, address, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, address, expiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store session: %w", err)
+	}
+	return nil
+}
+
+// GetSessionAddress returns the address of a live session.
+func (c *CRUD) GetSessionAddress(tokenHash string) (string, error) {
+	var address string
+	err := c.Connection.QueryRow(
+		context.Background(),
+		"SELECT address FROM sessions WHERE token_hash = $1 AND expires_at > NOW()",
+		tokenHash,
+	).Scan(&address)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrSessionNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch session: %w", err)
+	}
+	return address, nil
+}
+
+// DeleteExpiredAuth removes expired challenges and sessions.
+func (c *CRUD) DeleteExpiredAuth() error {
+	if _, err := c.Connection.Exec(context.Background(), "DELETE FROM auth_challenges WHERE expires_at <= NOW()"); err != nil {
+		return fmt.Errorf("failed to delete expired challenges: %w", err)
+	}
+	if _, err := c.Connection.Exec(context.Background(), "DELETE FROM sessions WHERE expires_at <= NOW()"); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
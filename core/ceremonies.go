@@ -0,0 +1,257 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrCeremonyNotFound = errors.New("ceremony session not found")
+	ErrKeygenInProgress = errors.New("key generation is already running")
+	ErrGroupKeyExists   = errors.New("organization already has a group key")
+)
+
+// runningKeygenIndex is the unique index allowing a single running key generation per organization.
+const runningKeygenIndex = "ceremony_sessions_running_keygen_idx"
+
+const ceremonyColumns = `id, kind, organization_id, transaction_id, initiator, participants, old_participants, new_participants, subject, status, round, result, blamed, reason, created_at, updated_at, instance_id`
+
+func scanCeremony(row pgx.Row) (types.CeremonySession, error) {
+	var s types.CeremonySession
+	var txID *int
+	err := row.Scan(
+		&s.ID, &s.Kind, &s.OrganizationID, &txID, &s.Initiator, &s.Participants, &s.OldParticipants, &s.NewParticipants,
+		&s.Subject, &s.Status, &s.Round, &s.Result, &s.Blamed, &s.Reason, &s.CreatedAt, &s.UpdatedAt, &s.Instance,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return s, ErrCeremonyNotFound
+	}
+	if txID != nil {
+		s.TransactionID = *txID
+	}
+	return s, err
+}
+
+// SaveCeremony inserts or updates the stored record of a ceremony session.
+func (c *CRUD) SaveCeremony(s types.CeremonySession) error {
+	return saveCeremony(c.Connection, s)
+}
+
+// GetCeremony fetches a ceremony session by its ID.
+func (c *CRUD) GetCeremony(id string) (types.CeremonySession, error) {
+	return scanCeremony(c.Connection.QueryRow(
+		context.Background(),
+		`SELECT `+ceremonyColumns+` FROM ceremony_sessions WHERE id = $1`, id,
+	))
+}
+
+// BeginKeygen stores a key generation session about to start. It fails while
+// another key generation of the organization runs, on any instance, and once
+// the organization has a group key.
+func (c *CRUD) BeginKeygen(s types.CeremonySession) error {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var hasKey bool
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT group_public_key IS NOT NULL FROM organizations WHERE id = $1 FOR UPDATE`,
+		s.OrganizationID,
+	).Scan(&hasKey)
+	if err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+	if hasKey {
+		return ErrGroupKeyExists
+	}
+
+	if err := saveCeremony(tx, s); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == runningKeygenIndex {
+			return ErrKeygenInProgress
+		}
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
+
+// CompleteKeygen stores the finished key generation session and the resulting
+// group public key on the organization in one transaction. The key is added to
+// the organization's key registry and the participants of the session hold the
+// shares of its first epoch.
+func (c *CRUD) CompleteKeygen(s types.CeremonySession) error {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := saveCeremony(tx, s); err != nil {
+		return err
+	}
+
+	tag, err := tx.Exec(
+		context.Background(),
+		`UPDATE organizations
+		 SET group_public_key = $2, key_epoch = 1, share_holders = $3, share_threshold = threshold,
+		     reshare_required = FALSE, key_refreshed_at = NOW()
+		 WHERE id = $1 AND group_public_key IS NULL`,
+		s.OrganizationID, s.Result, s.Participants,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store group public key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("organization %d already has a group public key", s.OrganizationID)
+	}
+	if err := storeKey(tx, s); err != nil {
+		return err
+	}
+	if err := carryOverTransactions(tx, s.OrganizationID, 1); err != nil {
+		return err
+	}
+
+	return tx.Commit(context.Background())
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+func saveCeremony(db execer, s types.CeremonySession) error {
+	var txID *int
+	if s.TransactionID != 0 {
+		txID = &s.TransactionID
+	}
+	_, err := db.Exec(
+		context.Background(),
+		`INSERT INTO ceremony_sessions (`+ceremonyColumns+`)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		 ON CONFLICT (id) DO UPDATE SET
+		     status = EXCLUDED.status,
+		     round = EXCLUDED.round,
+		     result = EXCLUDED.result,
+		     blamed = EXCLUDED.blamed,
+		     reason = EXCLUDED.reason,
+		     updated_at = EXCLUDED.updated_at`,
+		s.ID, s.Kind, s.OrganizationID, txID, s.Initiator, s.Participants, nonNil(s.OldParticipants), nonNil(s.NewParticipants),
+		s.Subject, s.Status, s.Round, s.Result, nonNil(s.Blamed), s.Reason, s.CreatedAt, s.UpdatedAt, s.Instance,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save ceremony session: %w", err)
+	}
+	return nil
+}
+
+// CompleteSigning stores the finished signing session and the signature on its
+// transaction, moving the transaction from signing to signed.
+func (c *CRUD) CompleteSigning(s types.CeremonySession) (types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := saveCeremony(tx, s); err != nil {
+		return types.Transaction{}, err
+	}
+
+	t, err := lockTransaction(tx, s.TransactionID)
+	if err != nil {
+		return t, err
+	}
+
+	_, err = tx.Exec(
+		context.Background(),
+		"UPDATE transactions SET signature = $2 WHERE id = $1",
+		t.ID, s.Result,
+	)
+	if err != nil {
+		return t, fmt.Errorf("failed to store signature: %w", err)
+	}
+
+	t, err = transition(tx, t, types.TransactionSigned, "")
+	if err != nil {
+		return t, err
+	}
+
+	return t, tx.Commit(context.Background())
+}
+
+// ReleaseStaleSignings moves transactions whose signing session has not moved
+// for staleAfter, e.g. because the instance running it went away, back to
+// approved and marks their sessions aborted.
+func (c *CRUD) ReleaseStaleSignings(staleAfter time.Duration) ([]types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	cutoff := time.Now().Add(-staleAfter)
+	rows, err := tx.Query(
+		context.Background(),
+		`SELECT `+transactionColumns+`
+		 FROM transactions t
+		 WHERE status = $1 AND updated_at < $2
+		   AND NOT EXISTS (
+		       SELECT 1 FROM ceremony_sessions c
+		       WHERE c.transaction_id = t.id AND c.kind = 'signing' AND c.status = $3 AND c.updated_at >= $2)
+		 FOR UPDATE SKIP LOCKED`, types.TransactionSigning, cutoff, types.CeremonyRunning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stale signings: %w", err)
+	}
+	stale, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (types.Transaction, error) {
+		return scanTransaction(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan transaction: %w", err)
+	}
+
+	released := make([]types.Transaction, 0, len(stale))
+	for _, t := range stale {
+		_, err := tx.Exec(
+			context.Background(),
+			`UPDATE ceremony_sessions
+			 SET status = $2, reason = 'session was lost', updated_at = NOW()
+			 WHERE transaction_id = $1 AND kind = 'signing' AND status = $3`,
+			t.ID, types.CeremonyAborted, types.CeremonyRunning,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to abort stale signing session: %w", err)
+		}
+		t, err = transition(tx, t, types.TransactionApproved, "")
+		if err != nil {
+			return nil, err
+		}
+		released = append(released, t)
+	}
+
+	return released, tx.Commit(context.Background())
+}
+
+// ReleaseStaleKeygens marks key generation sessions that have not moved for
+// staleAfter as aborted, so the organization's admin can start a new one.
+func (c *CRUD) ReleaseStaleKeygens(staleAfter time.Duration) (int64, error) {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		`UPDATE ceremony_sessions
+		 SET status = $2, reason = 'session was lost', updated_at = NOW()
+		 WHERE kind = 'keygen' AND status = $3 AND updated_at < $1`,
+		time.Now().Add(-staleAfter), types.CeremonyAborted, types.CeremonyRunning,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release stale key generations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
@@ -2,12 +2,20 @@ package crud
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"mpc-backend/types"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var ErrOrganizationExists = errors.New("an organization with this name already exists")
+
+// organizationNameKey is the unique constraint on organization names.
+const organizationNameKey = "organizations_name_key"
+
 type CRUD struct {
 	Connection *pgxpool.Pool
 }
@@ -16,7 +24,10 @@ func NewCRUD(conn *pgxpool.Pool) *CRUD {
 	return &CRUD{conn}
 }
 
-func (c *CRUD) CreateOrganization(name string, threshold int, participants []types.Participant) (int, error) {
+// CreateOrganization stores a new organization and invites the given participants
+// to it. Invitations can be answered until expiresAt; the admin joins right away
+// if it is among the participants.
+func (c *CRUD) CreateOrganization(name string, threshold int, participants []types.Participant, admin string, expiresAt time.Time) (int, error) {
 	tx, err := c.Connection.Begin(context.Background())
 	if err != nil {
 		return 0, err
@@ -26,20 +37,19 @@ func (c *CRUD) CreateOrganization(name string, threshold int, participants []typ
 	var orgID int
 	err = tx.QueryRow(
 		context.Background(),
-		"INSERT INTO organizations (name, threshold) VALUES ($1, $2) RETURNING id",
-		name, threshold,
+		"INSERT INTO organizations (name, threshold, admin) VALUES ($1, $2, $3) RETURNING id",
+		name, threshold, admin,
 	).Scan(&orgID)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == organizationNameKey {
+			return 0, ErrOrganizationExists
+		}
 		return 0, err
 	}
 
 	for _, p := range participants {
-		_, err := tx.Exec(
-			context.Background(),
-			"INSERT INTO participants (organization_id, address) VALUES ($1, $2)",
-			orgID, p.Address,
-		)
-		if err != nil {
+		if err := invite(tx, orgID, p.Address, admin, expiresAt); err != nil {
 			return 0, err
 		}
 	}
@@ -50,7 +60,8 @@ func (c *CRUD) CreateOrganization(name string, threshold int, participants []typ
 func (c *CRUD) GetOrganizationsByAddress(address string) ([]types.Organization, error) {
 	rows, err := c.Connection.Query(
 		context.Background(),
-		`SELECT o.id, o.name, o.threshold
+		`SELECT o.id, o.name, o.threshold,
+		        (SELECT COUNT(*) FROM participants a WHERE a.organization_id = o.id) >= o.threshold
 		 FROM organizations o
 		 JOIN participants p ON o.id = p.organization_id
 		 WHERE p.address = $1`, address)
@@ -62,7 +73,7 @@ func (c *CRUD) GetOrganizationsByAddress(address string) ([]types.Organization,
 	var orgs []types.Organization
 	for rows.Next() {
 		var org types.Organization
-		if err := rows.Scan(&org.ID, &org.Name, &org.Threshold); err != nil {
+		if err := rows.Scan(&org.ID, &org.Name, &org.Threshold, &org.Ready); err != nil {
 			return nil, err
 		}
 		orgs = append(orgs, org)
@@ -78,25 +89,96 @@ func (c *CRUD) GetOrganizationByName(name string) (types.Organization, error) {
 	var org types.Organization
 	err := c.Connection.QueryRow(context.Background(),
 		`
-        SELECT id, name, threshold
+        SELECT id, name, threshold, COALESCE(admin, ''), COALESCE(group_public_key, ''),
+               key_epoch, share_holders, share_threshold, reshare_required, COALESCE(reshare_session, '')
         FROM organizations
         WHERE name = $1
         `, name,
-	).Scan(&org.ID, &org.Name, &org.Threshold)
+	).Scan(
+		&org.ID, &org.Name, &org.Threshold, &org.Admin, &org.GroupPublicKey,
+		&org.KeyEpoch, &org.ShareHolders, &org.ShareThreshold, &org.ReshareRequired, &org.ReshareSession,
+	)
 	if err != nil {
 		return org, fmt.Errorf("failed to fetch organization: %w", err)
 	}
 
+	participants, err := c.getParticipants(org.ID)
+	if err != nil {
+		return org, err
+	}
+	org.Participants = participants
+	org.Ready = len(participants) >= org.Threshold
+
+	return org, nil
+}
+
+// GetOrganizationByID fetches a single organization by its ID, including its participants.
+func (c *CRUD) GetOrganizationByID(id int) (types.Organization, error) {
+	var org types.Organization
+	err := c.Connection.QueryRow(context.Background(),
+		`
+        SELECT id, name, threshold, COALESCE(admin, ''), COALESCE(group_public_key, ''),
+               key_epoch, share_holders, share_threshold, reshare_required, COALESCE(reshare_session, '')
+        FROM organizations
+        WHERE id = $1
+        `, id,
+	).Scan(
+		&org.ID, &org.Name, &org.Threshold, &org.Admin, &org.GroupPublicKey,
+		&org.KeyEpoch, &org.ShareHolders, &org.ShareThreshold, &org.ReshareRequired, &org.ReshareSession,
+	)
+	if err != nil {
+		return org, fmt.Errorf("failed to fetch organization: %w", err)
+	}
+
+	participants, err := c.getParticipants(org.ID)
+	if err != nil {
+		return org, err
+	}
+	org.Participants = participants
+	org.Ready = len(participants) >= org.Threshold
+
+	return org, nil
+}
+
+// IsParticipant reports whether an address is a participant of an organization,
+// i.e. has accepted its invitation.
+func (c *CRUD) IsParticipant(orgID int, address string) (bool, error) {
+	var member bool
+	err := c.Connection.QueryRow(
+		context.Background(),
+		"SELECT EXISTS (SELECT 1 FROM participants WHERE organization_id = $1 AND address = $2)",
+		orgID, address,
+	).Scan(&member)
+	if err != nil {
+		return false, fmt.Errorf("failed to check participant: %w", err)
+	}
+	return member, nil
+}
+
+// GetParticipantAddresses returns the addresses of an organization's participants.
+func (c *CRUD) GetParticipantAddresses(orgID int) ([]string, error) {
+	participants, err := c.getParticipants(orgID)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0, len(participants))
+	for _, p := range participants {
+		addresses = append(addresses, p.Address)
+	}
+	return addresses, nil
+}
+
+func (c *CRUD) getParticipants(orgID int) ([]types.Participant, error) {
 	// Fetch participants for the organization (select only the address)
 	rows, err := c.Connection.Query(context.Background(),
 		`
         SELECT address
         FROM participants
         WHERE organization_id = $1
-        `, org.ID,
+        `, orgID,
 	)
 	if err != nil {
-		return org, fmt.Errorf("failed to fetch participants: %w", err)
+		return nil, fmt.Errorf("failed to fetch participants: %w", err)
 	}
 	defer rows.Close()
 
@@ -104,14 +186,12 @@ func (c *CRUD) GetOrganizationByName(name string) (types.Organization, error) {
 	for rows.Next() {
 		var p types.Participant
 		if err := rows.Scan(&p.Address); err != nil {
-			return org, fmt.Errorf("failed to scan participant: %w", err)
+			return nil, fmt.Errorf("failed to scan participant: %w", err)
 		}
 		participants = append(participants, p)
 	}
 	if err := rows.Err(); err != nil {
-		return org, fmt.Errorf("error iterating participants: %w", err)
+		return nil, fmt.Errorf("error iterating participants: %w", err)
 	}
-	org.Participants = participants
-
-	return org, nil
+	return participants, nil
 }
@@ -0,0 +1,45 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrEncryptionKeyNotFound = errors.New("no encryption key registered for address")
+
+// SetEncryptionKey registers or replaces the X25519 public key of an address.
+func (c *CRUD) SetEncryptionKey(address, publicKey string) (types.EncryptionKey, error) {
+	key := types.EncryptionKey{Address: address, PublicKey: publicKey}
+	err := c.Connection.QueryRow(
+		context.Background(),
+		`INSERT INTO encryption_keys (address, public_key) VALUES ($1, $2)
+		 ON CONFLICT (address) DO UPDATE SET public_key = EXCLUDED.public_key, updated_at = NOW()
+		 RETURNING updated_at`,
+		address, publicKey,
+	).Scan(&key.UpdatedAt)
+	if err != nil {
+		return key, fmt.Errorf("failed to store encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// GetEncryptionKey fetches the X25519 public key registered by an address.
+func (c *CRUD) GetEncryptionKey(address string) (types.EncryptionKey, error) {
+	key := types.EncryptionKey{Address: address}
+	err := c.Connection.QueryRow(
+		context.Background(),
+		"SELECT public_key, updated_at FROM encryption_keys WHERE address = $1",
+		address,
+	).Scan(&key.PublicKey, &key.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return key, ErrEncryptionKeyNotFound
+	}
+	if err != nil {
+		return key, fmt.Errorf("failed to fetch encryption key: %w", err)
+	}
+	return key, nil
+}
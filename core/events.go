@@ -0,0 +1,135 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrEventsPruned is returned when part of the requested event range is no longer retained.
+var ErrEventsPruned = errors.New("organization events past the requested sequence were pruned")
+
+// AppendOrganizationEvent assigns the next sequence number of the organization
+// to a frame and stores it. Sequence numbers increase by one per event and are
+// allocated under the organization's row lock, so they never repeat or skip.
+// A non-zero outboxID names the outbox event the frame belongs to; appending
+// the same event again returns the frame stored the first time.
+func (c *CRUD) AppendOrganizationEvent(orgID int, outboxID int64, frame types.Frame) (types.Frame, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return frame, err
+	}
+	defer tx.Rollback(context.Background())
+
+	// The organization's row lock also serializes the check against a concurrent append of the same event.
+	_, err = tx.Exec(context.Background(), `SELECT 1 FROM organizations WHERE id = $1 FOR UPDATE`, orgID)
+	if err != nil {
+		return frame, fmt.Errorf("failed to lock organization: %w", err)
+	}
+	if outboxID != 0 {
+		var stored []byte
+		err = tx.QueryRow(
+			context.Background(),
+			`SELECT frame FROM organization_events WHERE outbox_id = $1`, outboxID,
+		).Scan(&stored)
+		if err == nil {
+			var existing types.Frame
+			if err := json.Unmarshal(stored, &existing); err != nil {
+				return frame, fmt.Errorf("failed to decode organization event: %w", err)
+			}
+			return existing, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return frame, fmt.Errorf("failed to look up organization event: %w", err)
+		}
+	}
+
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE organizations SET event_seq = event_seq + 1 WHERE id = $1 RETURNING event_seq`, orgID,
+	).Scan(&frame.Seq)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return frame, fmt.Errorf("organization %d not found", orgID)
+		}
+		return frame, fmt.Errorf("failed to allocate event sequence: %w", err)
+	}
+
+	encoded, err := json.Marshal(frame)
+	if err != nil {
+		return frame, err
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`INSERT INTO organization_events (organization_id, seq, frame, outbox_id) VALUES ($1, $2, $3, NULLIF($4, 0))`,
+		orgID, frame.Seq, encoded, outboxID,
+	)
+	if err != nil {
+		return frame, fmt.Errorf("failed to store organization event: %w", err)
+	}
+
+	return frame, tx.Commit(context.Background())
+}
+
+// GetOrganizationEvents returns the events of an organization after the given
+// sequence number in order. ErrEventsPruned is returned when events right
+// after it are no longer retained.
+func (c *CRUD) GetOrganizationEvents(orgID int, after int64) ([]types.OrganizationEvent, error) {
+	var latest int64
+	err := c.Connection.QueryRow(
+		context.Background(),
+		`SELECT event_seq FROM organizations WHERE id = $1`, orgID,
+	).Scan(&latest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch event sequence: %w", err)
+	}
+	if after >= latest {
+		return []types.OrganizationEvent{}, nil
+	}
+
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT seq, frame
+		 FROM organization_events
+		 WHERE organization_id = $1 AND seq > $2
+		 ORDER BY seq`, orgID, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch organization events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []types.OrganizationEvent{}
+	for rows.Next() {
+		var e types.OrganizationEvent
+		if err := rows.Scan(&e.Seq, &e.Frame); err != nil {
+			return nil, fmt.Errorf("failed to scan organization event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating organization events: %w", err)
+	}
+
+	if len(events) == 0 || events[0].Seq != after+1 {
+		return nil, ErrEventsPruned
+	}
+	return events, nil
+}
+
+// PruneOrganizationEvents deletes organization events older than the retention window.
+func (c *CRUD) PruneOrganizationEvents(retention time.Duration) (int64, error) {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		"DELETE FROM organization_events WHERE created_at < $1",
+		time.Now().Add(-retention),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune organization events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
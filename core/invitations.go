@@ -0,0 +1,232 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrInvitationNotPending = errors.New("invitation has already been answered or has expired")
+)
+
+const invitationColumns = `i.id, i.organization_id, o.name, i.address, i.status, i.invited_by, i.created_at, i.expires_at, i.responded_at`
+
+func scanInvitation(row pgx.Row) (types.Invitation, error) {
+	var inv types.Invitation
+	err := row.Scan(
+		&inv.ID, &inv.OrganizationID, &inv.OrganizationName, &inv.Address, &inv.Status,
+		&inv.InvitedBy, &inv.CreatedAt, &inv.ExpiresAt, &inv.RespondedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return inv, ErrInvitationNotFound
+	}
+	return inv, err
+}
+
+func scanInvitations(rows pgx.Rows) ([]types.Invitation, error) {
+	defer rows.Close()
+
+	invitations := []types.Invitation{}
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invitations: %w", err)
+	}
+	return invitations, nil
+}
+
+// invite stores an invitation of an organization and records it in the outbox
+// so the invitee is told about it. The inviter's own invitation is accepted
+// right away. Declined and expired invitations are sent again, pending ones
+// are sent again with the new expiry; addresses with an accepted invitation
+// are skipped.
+func invite(tx pgx.Tx, orgID int, address, invitedBy string, expiresAt time.Time) error {
+	status := types.InvitationPending
+	var respondedAt *time.Time
+	if address == invitedBy {
+		now := time.Now().UTC()
+		status, respondedAt = types.InvitationAccepted, &now
+	}
+
+	inv, err := scanInvitation(tx.QueryRow(
+		context.Background(),
+		`WITH i AS (
+		   INSERT INTO invitations (organization_id, address, status, invited_by, expires_at, responded_at)
+		   VALUES ($1, $2, $3, $4, $5, $6)
+		   ON CONFLICT (organization_id, address) DO UPDATE
+		   SET status = EXCLUDED.status, invited_by = EXCLUDED.invited_by, created_at = NOW(),
+		       expires_at = EXCLUDED.expires_at, responded_at = EXCLUDED.responded_at
+		   WHERE invitations.status IN ('pending', 'declined', 'expired')
+		   RETURNING *
+		 )
+		 SELECT `+invitationColumns+` FROM i JOIN organizations o ON o.id = i.organization_id`,
+		orgID, address, status, invitedBy, expiresAt, respondedAt,
+	))
+	if errors.Is(err, ErrInvitationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store invitation: %w", err)
+	}
+
+	if status == types.InvitationAccepted {
+		return addParticipant(tx, orgID, address)
+	}
+	return recordEvent(tx, orgID, types.EventInvitationCreated, invitedBy, inv)
+}
+
+// addParticipant makes an address a participant of an organization unless it
+// already is one. A participant joining an organization with a group key holds
+// no share yet, so the key has to be reshared.
+func addParticipant(tx pgx.Tx, orgID int, address string) error {
+	tag, err := tx.Exec(
+		context.Background(),
+		`INSERT INTO participants (organization_id, address)
+		 SELECT $1, $2
+		 WHERE NOT EXISTS (SELECT 1 FROM participants WHERE organization_id = $1 AND address = $2)`,
+		orgID, address,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add participant: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`UPDATE organizations SET reshare_required = TRUE WHERE id = $1 AND group_public_key IS NOT NULL`, orgID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to require reshare: %w", err)
+	}
+	return nil
+}
+
+// GetInvitationsByAddress returns the invitations of an address, newest first.
+// An empty status returns invitations in every state.
+func (c *CRUD) GetInvitationsByAddress(address string, status types.InvitationStatus) ([]types.Invitation, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT `+invitationColumns+`
+		 FROM invitations i
+		 JOIN organizations o ON o.id = i.organization_id
+		 WHERE i.address = $1 AND ($2 = '' OR i.status = $2)
+		 ORDER BY i.id DESC`, address, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch invitations: %w", err)
+	}
+	return scanInvitations(rows)
+}
+
+// GetOrganizationInvitations returns every invitation of an organization in the order they were sent.
+func (c *CRUD) GetOrganizationInvitations(orgID int) ([]types.Invitation, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT `+invitationColumns+`
+		 FROM invitations i
+		 JOIN organizations o ON o.id = i.organization_id
+		 WHERE i.organization_id = $1
+		 ORDER BY i.id`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch invitations: %w", err)
+	}
+	return scanInvitations(rows)
+}
+
+// RespondToInvitation accepts or declines a pending invitation of an address.
+// Accepting makes the address a participant of the organization.
+func (c *CRUD) RespondToInvitation(id int, address string, accept bool) (types.Invitation, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.Invitation{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	inv, err := scanInvitation(tx.QueryRow(
+		context.Background(),
+		`SELECT `+invitationColumns+`
+		 FROM invitations i
+		 JOIN organizations o ON o.id = i.organization_id
+		 WHERE i.id = $1 AND i.address = $2
+		 FOR UPDATE OF i`, id, address,
+	))
+	if err != nil {
+		return inv, err
+	}
+	// An invitation past its expiry is refused even before the expiry job gets to it.
+	if inv.Status != types.InvitationPending || !time.Now().Before(inv.ExpiresAt) {
+		return inv, ErrInvitationNotPending
+	}
+
+	inv.Status = types.InvitationDeclined
+	event := types.EventInvitationDeclined
+	if accept {
+		inv.Status = types.InvitationAccepted
+		event = types.EventInvitationAccepted
+	}
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE invitations SET status = $2, responded_at = NOW() WHERE id = $1 RETURNING responded_at`,
+		id, inv.Status,
+	).Scan(&inv.RespondedAt)
+	if err != nil {
+		return inv, fmt.Errorf("failed to update invitation: %w", err)
+	}
+
+	if accept {
+		if err := addParticipant(tx, inv.OrganizationID, address); err != nil {
+			return inv, err
+		}
+	}
+	if err := recordEvent(tx, inv.OrganizationID, event, address, inv); err != nil {
+		return inv, err
+	}
+
+	return inv, tx.Commit(context.Background())
+}
+
+// ExpireInvitations moves every pending invitation past its expiry to expired.
+func (c *CRUD) ExpireInvitations() ([]types.Invitation, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	rows, err := tx.Query(
+		context.Background(),
+		`WITH i AS (
+		   UPDATE invitations SET status = $1
+		   WHERE status = $2 AND expires_at <= NOW()
+		   RETURNING *
+		 )
+		 SELECT `+invitationColumns+` FROM i JOIN organizations o ON o.id = i.organization_id`,
+		types.InvitationExpired, types.InvitationPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire invitations: %w", err)
+	}
+	expired, err := scanInvitations(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inv := range expired {
+		if err := recordEvent(tx, inv.OrganizationID, types.EventInvitationExpired, "", inv); err != nil {
+			return nil, err
+		}
+	}
+
+	return expired, tx.Commit(context.Background())
+}
@@ -0,0 +1,265 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrMembershipConflict = errors.New("membership change does not apply to the current members")
+	ErrProposalInFlight   = errors.New("another membership proposal of the organization is pending")
+)
+
+// membershipPendingIndex is the unique index allowing a single pending membership proposal per organization.
+const membershipPendingIndex = "transactions_membership_pending_idx"
+
+// CheckMembershipChange reports whether a membership proposal can be applied to
+// the organization as it is now: removed addresses must be participants, added
+// ones must not, someone must remain and the remaining participants must be
+// able to reach the threshold on their own, as invitees only count once they
+// accept.
+func CheckMembershipChange(org types.Organization, m types.MembershipPayload) error {
+	participants := make([]string, 0, len(org.Participants))
+	for _, p := range org.Participants {
+		participants = append(participants, p.Address)
+	}
+	return checkMembership(participants, org.Threshold, m)
+}
+
+func checkMembership(participants []string, threshold int, m types.MembershipPayload) error {
+	member := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		member[p] = true
+	}
+	for _, addr := range m.Remove {
+		if !member[addr] {
+			return fmt.Errorf("%w: %s is not a participant", ErrMembershipConflict, addr)
+		}
+	}
+	for _, addr := range m.Add {
+		if member[addr] {
+			return fmt.Errorf("%w: %s is already a participant", ErrMembershipConflict, addr)
+		}
+	}
+
+	remaining := len(participants) - len(m.Remove)
+	if remaining < 1 {
+		return fmt.Errorf("%w: at least one participant must remain", ErrMembershipConflict)
+	}
+	if m.Threshold != 0 {
+		threshold = m.Threshold
+	}
+	if threshold > remaining {
+		return fmt.Errorf("%w: threshold %d exceeds the %d remaining participants", ErrMembershipConflict, threshold, remaining)
+	}
+	return nil
+}
+
+// applyMembership carries out an approved membership proposal: removed
+// participants leave, added addresses are invited until inviteExpiresAt, the
+// threshold is updated and the other pending transactions are re-evaluated.
+// The change is recorded in the outbox, which tells the organization whether
+// its key has to be reshared.
+func applyMembership(tx pgx.Tx, t types.Transaction, inviteExpiresAt time.Time) error {
+	m := *t.Payload.Membership
+
+	var threshold int
+	var groupKey string
+	err := tx.QueryRow(
+		context.Background(),
+		`SELECT threshold, COALESCE(group_public_key, '') FROM organizations WHERE id = $1 FOR UPDATE`,
+		t.OrganizationID,
+	).Scan(&threshold, &groupKey)
+	if err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+
+	rows, err := tx.Query(
+		context.Background(),
+		`SELECT address FROM participants WHERE organization_id = $1`, t.OrganizationID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch participants: %w", err)
+	}
+	participants, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to scan participants: %w", err)
+	}
+
+	if err := checkMembership(participants, threshold, m); err != nil {
+		return err
+	}
+
+	if len(m.Remove) > 0 {
+		_, err = tx.Exec(
+			context.Background(),
+			`DELETE FROM participants WHERE organization_id = $1 AND address = ANY($2)`,
+			t.OrganizationID, m.Remove,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove participants: %w", err)
+		}
+		// Dropping the invitations lets removed addresses be invited again later.
+		_, err = tx.Exec(
+			context.Background(),
+			`DELETE FROM invitations WHERE organization_id = $1 AND address = ANY($2)`,
+			t.OrganizationID, m.Remove,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to remove invitations: %w", err)
+		}
+	}
+
+	for _, addr := range m.Add {
+		if err := invite(tx, t.OrganizationID, addr, t.Initiator, inviteExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	thresholdChanged := m.Threshold != 0 && m.Threshold != threshold
+	if thresholdChanged {
+		threshold = m.Threshold
+		_, err = tx.Exec(
+			context.Background(),
+			`UPDATE organizations SET threshold = $2 WHERE id = $1`,
+			t.OrganizationID, threshold,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to update threshold: %w", err)
+		}
+	}
+
+	// Participants added later ask for a reshare themselves once they accept.
+	reshareRequired := groupKey != "" && (len(m.Remove) > 0 || thresholdChanged)
+	if reshareRequired {
+		_, err = tx.Exec(
+			context.Background(),
+			`UPDATE organizations SET reshare_required = TRUE WHERE id = $1`, t.OrganizationID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to require reshare: %w", err)
+		}
+	}
+
+	removed := make(map[string]bool, len(m.Remove))
+	for _, addr := range m.Remove {
+		removed[addr] = true
+	}
+	remaining := make([]string, 0, len(participants))
+	for _, addr := range participants {
+		if !removed[addr] {
+			remaining = append(remaining, addr)
+		}
+	}
+
+	// Invitations alone change neither the decisions nor the threshold.
+	if len(m.Remove) > 0 || thresholdChanged {
+		if err := reevaluatePending(tx, t, m.Remove, len(remaining), threshold); err != nil {
+			return err
+		}
+	}
+
+	change := types.MembershipChange{
+		OrganizationID:  t.OrganizationID,
+		ProposalID:      t.ID,
+		Added:           nonNil(m.Add),
+		Removed:         nonNil(m.Remove),
+		Threshold:       threshold,
+		Participants:    remaining,
+		ReshareRequired: reshareRequired,
+		Message:         "Membership change applied",
+	}
+	if change.ReshareRequired {
+		change.Message = "Membership change applied, the group key has to be reshared"
+	}
+	return recordEvent(tx, t.OrganizationID, types.EventMembershipChanged, t.Initiator, change)
+}
+
+// reevaluatePending carries a membership change over to the other pending
+// transactions of the organization: decisions of removed participants no longer
+// count and the new threshold applies. Transactions that now reach the threshold
+// are approved, ones that can no longer reach it with the remaining participants
+// are rejected.
+func reevaluatePending(tx pgx.Tx, t types.Transaction, removed []string, participants, threshold int) error {
+	if len(removed) > 0 {
+		_, err := tx.Exec(
+			context.Background(),
+			`DELETE FROM transaction_approvals a USING transactions p
+			 WHERE a.transaction_id = p.id AND p.organization_id = $1 AND p.status = $2 AND p.id <> $3
+			   AND a.address = ANY($4)`,
+			t.OrganizationID, types.TransactionPending, t.ID, removed,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to drop decisions of removed participants: %w", err)
+		}
+	}
+
+	rows, err := tx.Query(
+		context.Background(),
+		`UPDATE transactions p
+		 SET threshold = $4, updated_at = NOW(),
+		     confirmations = (SELECT COUNT(*) FROM transaction_approvals a WHERE a.transaction_id = p.id AND a.decision = $5)
+		 WHERE organization_id = $1 AND status = $2 AND id <> $3
+		 RETURNING `+transactionColumns,
+		t.OrganizationID, types.TransactionPending, t.ID, threshold, decisionApprove,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to recount pending transactions: %w", err)
+	}
+	var pending []types.Transaction
+	for rows.Next() {
+		p, err := scanTransaction(rows)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating transactions: %w", err)
+	}
+
+	for _, p := range pending {
+		var rejections int
+		err := tx.QueryRow(
+			context.Background(),
+			`SELECT COUNT(*) FROM transaction_approvals WHERE transaction_id = $1 AND decision = $2`,
+			p.ID, decisionReject,
+		).Scan(&rejections)
+		if err != nil {
+			return fmt.Errorf("failed to count rejections: %w", err)
+		}
+		if to := reevaluatedStatus(p, participants, rejections); to != p.Status {
+			if _, err := transition(tx, p, to, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reevaluatedStatus decides a pending transaction after a membership change:
+// approved once its confirmations reach the threshold, rejected once the
+// participants that have not rejected it can no longer reach it.
+func reevaluatedStatus(p types.Transaction, participants, rejections int) types.TransactionStatus {
+	switch {
+	case p.Confirmations >= p.Threshold:
+		return types.TransactionApproved
+	case participants-rejections < p.Threshold:
+		return types.TransactionRejected
+	default:
+		return p.Status
+	}
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
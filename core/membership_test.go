@@ -0,0 +1,63 @@
+package crud
+
+import (
+	"errors"
+	"mpc-backend/types"
+	"testing"
+)
+
+func TestCheckMembership(t *testing.T) {
+	participants := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name      string
+		threshold int
+		change    types.MembershipPayload
+		err       error
+	}{
+		{"add", 2, types.MembershipPayload{Add: []string{"d"}}, nil},
+		{"remove", 2, types.MembershipPayload{Remove: []string{"c"}}, nil},
+		{"lower threshold", 2, types.MembershipPayload{Threshold: 1}, nil},
+		{"raise threshold to remaining", 2, types.MembershipPayload{Remove: []string{"c"}, Threshold: 2}, nil},
+		{"remove outsider", 2, types.MembershipPayload{Remove: []string{"d"}}, ErrMembershipConflict},
+		{"add participant", 2, types.MembershipPayload{Add: []string{"a"}}, ErrMembershipConflict},
+		{"remove everyone", 1, types.MembershipPayload{Remove: participants}, ErrMembershipConflict},
+		{"threshold above remaining", 2, types.MembershipPayload{Remove: []string{"b", "c"}}, ErrMembershipConflict},
+		// Invitees only count once they accept.
+		{"threshold relies on invitees", 2, types.MembershipPayload{Add: []string{"d", "e"}, Threshold: 4}, ErrMembershipConflict},
+		{"swap above remaining", 3, types.MembershipPayload{Add: []string{"d"}, Remove: []string{"c"}}, ErrMembershipConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkMembership(participants, tt.threshold, tt.change); !errors.Is(err, tt.err) {
+				t.Fatalf("checkMembership() = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReevaluatedStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		confirmations int
+		threshold     int
+		participants  int
+		rejections    int
+		want          types.TransactionStatus
+	}{
+		{"still reachable", 1, 2, 3, 1, types.TransactionPending},
+		{"threshold lowered to confirmations", 2, 2, 3, 0, types.TransactionApproved},
+		{"approval of removed participant dropped", 1, 2, 2, 0, types.TransactionPending},
+		{"unreachable after removal", 0, 2, 2, 1, types.TransactionRejected},
+		{"unreachable after raise", 1, 3, 3, 1, types.TransactionRejected},
+		{"confirmations win over rejections", 2, 2, 3, 1, types.TransactionApproved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := types.Transaction{Status: types.TransactionPending, Confirmations: tt.confirmations, Threshold: tt.threshold}
+			if got := reevaluatedStatus(p, tt.participants, tt.rejections); got != tt.want {
+				t.Fatalf("reevaluatedStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
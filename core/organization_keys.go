@@ -0,0 +1,137 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrKeyNotFound     = errors.New("organization key not found")
+	ErrAccountNotFound = errors.New("key account not found")
+	ErrAccountExists   = errors.New("an account with this path already exists for the key")
+)
+
+const organizationKeyColumns = `id, organization_id, public_key, curve, protocol, epoch, COALESCE(ceremony_id, ''), created_at`
+
+func scanOrganizationKey(row pgx.Row) (types.OrganizationKey, error) {
+	var k types.OrganizationKey
+	err := row.Scan(&k.ID, &k.OrganizationID, &k.PublicKey, &k.Curve, &k.Protocol, &k.Epoch, &k.CeremonyID, &k.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return k, ErrKeyNotFound
+	}
+	return k, err
+}
+
+// storeKey registers the group public key produced by a key generation session.
+func storeKey(tx pgx.Tx, s types.CeremonySession) error {
+	_, err := tx.Exec(
+		context.Background(),
+		`INSERT INTO organization_keys (organization_id, public_key, curve, protocol, epoch, ceremony_id)
+		 VALUES ($1, $2, $3, $4, 1, $5)`,
+		s.OrganizationID, s.Result, types.CurveSecp256k1, types.ProtocolECDSA, s.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store organization key: %w", err)
+	}
+	return nil
+}
+
+// GetOrganizationKeys returns the keys of an organization in the order they
+// were generated, each with its accounts in the order they were created.
+func (c *CRUD) GetOrganizationKeys(orgID int) ([]types.OrganizationKey, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT `+organizationKeyColumns+` FROM organization_keys WHERE organization_id = $1 ORDER BY id`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch organization keys: %w", err)
+	}
+	keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (types.OrganizationKey, error) {
+		return scanOrganizationKey(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan organization key: %w", err)
+	}
+
+	rows, err = c.Connection.Query(
+		context.Background(),
+		`SELECT a.id, a.key_id, a.path, a.label, a.public_key, a.created_at
+		 FROM organization_key_accounts a
+		 JOIN organization_keys k ON k.id = a.key_id
+		 WHERE k.organization_id = $1
+		 ORDER BY a.id`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key accounts: %w", err)
+	}
+	accounts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (types.KeyAccount, error) {
+		var a types.KeyAccount
+		err := row.Scan(&a.ID, &a.KeyID, &a.Path, &a.Label, &a.PublicKey, &a.CreatedAt)
+		return a, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan key account: %w", err)
+	}
+
+	byKey := make(map[int][]types.KeyAccount)
+	for _, a := range accounts {
+		byKey[a.KeyID] = append(byKey[a.KeyID], a)
+	}
+	for i := range keys {
+		keys[i].Accounts = byKey[keys[i].ID]
+		if keys[i].Accounts == nil {
+			keys[i].Accounts = []types.KeyAccount{}
+		}
+	}
+	return keys, nil
+}
+
+// GetOrganizationKey fetches a single key of an organization.
+func (c *CRUD) GetOrganizationKey(orgID, keyID int) (types.OrganizationKey, error) {
+	return scanOrganizationKey(c.Connection.QueryRow(
+		context.Background(),
+		`SELECT `+organizationKeyColumns+` FROM organization_keys WHERE id = $1 AND organization_id = $2`,
+		keyID, orgID,
+	))
+}
+
+// CreateKeyAccount stores an account of a key at a derivation path. The path
+// must be in canonical form and publicKey the key derived along it.
+func (c *CRUD) CreateKeyAccount(keyID int, path, label, publicKey string) (types.KeyAccount, error) {
+	a := types.KeyAccount{KeyID: keyID, Path: path, Label: label, PublicKey: publicKey}
+	err := c.Connection.QueryRow(
+		context.Background(),
+		`INSERT INTO organization_key_accounts (key_id, path, label, public_key)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING id, created_at`,
+		keyID, path, label, publicKey,
+	).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return a, ErrAccountExists
+		}
+		return a, fmt.Errorf("failed to store key account: %w", err)
+	}
+	return a, nil
+}
+
+// GetKeyAccount fetches an account of one of the organization's keys.
+func (c *CRUD) GetKeyAccount(orgID, accountID int) (types.KeyAccount, error) {
+	var a types.KeyAccount
+	err := c.Connection.QueryRow(
+		context.Background(),
+		`SELECT a.id, a.key_id, a.path, a.label, a.public_key, a.created_at
+		 FROM organization_key_accounts a
+		 JOIN organization_keys k ON k.id = a.key_id
+		 WHERE a.id = $1 AND k.organization_id = $2`,
+		accountID, orgID,
+	).Scan(&a.ID, &a.KeyID, &a.Path, &a.Label, &a.PublicKey, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return a, ErrAccountNotFound
+	}
+	return a, err
+}
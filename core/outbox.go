@@ -0,0 +1,124 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+)
+
+// recordEvent stores a domain event in the outbox. It must run in the same
+// database transaction as the change the event describes, so the event is
+// dispatched if and only if the change is committed.
+func recordEvent(db execer, orgID int, event, actor string, payload interface{}) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", event, err)
+	}
+	_, err = db.Exec(
+		context.Background(),
+		`INSERT INTO outbox (organization_id, event, actor, payload) VALUES ($1, $2, $3, $4)`,
+		orgID, event, actor, encoded,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record %s event: %w", event, err)
+	}
+	return nil
+}
+
+// transitionEvent names the event recorded when a transaction moves between
+// two states, or returns "" for transitions that are not announced.
+func transitionEvent(from, to types.TransactionStatus) string {
+	switch to {
+	case types.TransactionApproved:
+		// Going back from signing to approved is a retry, not a new approval.
+		if from == types.TransactionPending {
+			return types.EventTransactionThresholdReached
+		}
+	case types.TransactionRejected:
+		return types.EventTransactionRejected
+	case types.TransactionCancelled:
+		return types.EventTransactionCancelled
+	case types.TransactionSigned:
+		return types.EventTransactionSigned
+	case types.TransactionExpired:
+		return types.EventTransactionExpired
+	case types.TransactionApplied:
+		return types.EventTransactionApplied
+	}
+	return ""
+}
+
+// ClaimOutboxEvents picks up to limit due events and locks them for lease, so
+// other instances leave them alone while they are dispatched. Only the oldest
+// pending event of each organization is eligible, which keeps the events of an
+// organization in order even when several instances dispatch concurrently.
+func (c *CRUD) ClaimOutboxEvents(limit int, lease time.Duration) ([]types.OutboxEvent, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`WITH heads AS (
+		   SELECT DISTINCT ON (organization_id) id, next_attempt_at, locked_until
+		   FROM outbox
+		   WHERE status = $1
+		   ORDER BY organization_id, id
+		 ), due AS (
+		   SELECT id FROM heads
+		   WHERE next_attempt_at <= NOW() AND (locked_until IS NULL OR locked_until <= NOW())
+		   ORDER BY id
+		   LIMIT $2
+		 )
+		 UPDATE outbox o SET locked_until = $3
+		 FROM due
+		 WHERE o.id = due.id AND o.status = $1 AND (o.locked_until IS NULL OR o.locked_until <= NOW())
+		 RETURNING o.id, o.organization_id, o.event, o.actor, o.payload, o.attempts, o.created_at`,
+		types.OutboxPending, limit, time.Now().Add(lease),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []types.OutboxEvent
+	for rows.Next() {
+		var e types.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.OrganizationID, &e.Event, &e.Actor, &e.Payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		claimed = append(claimed, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox events: %w", err)
+	}
+	return claimed, nil
+}
+
+// RecordOutboxAttempt stores the outcome of dispatching an event and releases
+// its lock. A pending status schedules the next attempt at next.
+func (c *CRUD) RecordOutboxAttempt(id int64, status types.OutboxStatus, lastError string, next time.Time) error {
+	_, err := c.Connection.Exec(
+		context.Background(),
+		`UPDATE outbox
+		 SET status = $2, attempts = attempts + 1, last_error = $3, next_attempt_at = $4, locked_until = NULL,
+		     dispatched_at = CASE WHEN $2 = 'dispatched' THEN NOW() ELSE dispatched_at END
+		 WHERE id = $1`,
+		id, status, lastError, next,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox attempt: %w", err)
+	}
+	return nil
+}
+
+// PruneOutboxEvents deletes dispatched and failed events older than the retention window.
+func (c *CRUD) PruneOutboxEvents(retention time.Duration) (int64, error) {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		"DELETE FROM outbox WHERE status <> $1 AND created_at < $2",
+		types.OutboxPending, time.Now().Add(-retention),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune outbox events: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
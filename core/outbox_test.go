@@ -0,0 +1,72 @@
+package crud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"mpc-backend/types"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTransitionEvent(t *testing.T) {
+	tests := []struct {
+		from, to types.TransactionStatus
+		want     string
+	}{
+		{types.TransactionPending, types.TransactionApproved, types.EventTransactionThresholdReached},
+		// A signing retry is not announced as a new approval.
+		{types.TransactionSigning, types.TransactionApproved, ""},
+		{types.TransactionApproved, types.TransactionSigning, ""},
+		{types.TransactionPending, types.TransactionRejected, types.EventTransactionRejected},
+		{types.TransactionPending, types.TransactionCancelled, types.EventTransactionCancelled},
+		{types.TransactionSigning, types.TransactionSigned, types.EventTransactionSigned},
+		{types.TransactionPending, types.TransactionExpired, types.EventTransactionExpired},
+		{types.TransactionApproved, types.TransactionApplied, types.EventTransactionApplied},
+		{types.TransactionSigned, types.TransactionBroadcast, ""},
+	}
+	for _, tt := range tests {
+		if got := transitionEvent(tt.from, tt.to); got != tt.want {
+			t.Errorf("transitionEvent(%s, %s) = %q, want %q", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+// recordingExecer keeps the arguments of the statements it is given.
+type recordingExecer struct {
+	args [][]any
+	err  error
+}
+
+func (r *recordingExecer) Exec(_ context.Context, _ string, args ...any) (pgconn.CommandTag, error) {
+	r.args = append(r.args, args)
+	return pgconn.CommandTag{}, r.err
+}
+
+func TestRecordEvent(t *testing.T) {
+	db := &recordingExecer{}
+	txn := types.Transaction{ID: 3, OrganizationID: 7}
+	if err := recordEvent(db, 7, types.EventTransactionSigned, "0xabc", txn); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.args) != 1 {
+		t.Fatalf("ran %d statements, want 1", len(db.args))
+	}
+	args := db.args[0]
+	if args[0] != 7 || args[1] != types.EventTransactionSigned || args[2] != "0xabc" {
+		t.Fatalf("recorded %v, want organization 7, the event and its actor", args[:3])
+	}
+	var stored types.Transaction
+	if err := json.Unmarshal(args[3].([]byte), &stored); err != nil || stored.ID != 3 {
+		t.Fatalf("stored payload %s (%v), want transaction 3", args[3], err)
+	}
+
+	failing := &recordingExecer{err: errors.New("boom")}
+	if err := recordEvent(failing, 7, types.EventTransactionSigned, "", txn); err == nil {
+		t.Error("a failed insert was not reported")
+	}
+	if err := recordEvent(db, 7, types.EventTransactionSigned, "", make(chan int)); err == nil {
+		t.Error("an unencodable payload was not reported")
+	}
+}
@@ -0,0 +1,77 @@
+package crud
+
+import (
+	"context"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+)
+
+// EnqueueMessage stores a message for an address that could not be reached.
+// A non-zero outboxID names the outbox event the message belongs to; queueing
+// the same event for the same address again is a no-op.
+func (c *CRUD) EnqueueMessage(address string, outboxID int64, payload []byte) error {
+	_, err := c.Connection.Exec(
+		context.Background(),
+		`INSERT INTO queued_messages (address, payload, outbox_id) VALUES ($1, $2, NULLIF($3, 0))
+		 ON CONFLICT DO NOTHING`,
+		address, payload, outboxID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message: %w", err)
+	}
+	return nil
+}
+
+// GetQueuedMessages returns the undelivered messages of an address in the order they were queued.
+func (c *CRUD) GetQueuedMessages(address string) ([]types.QueuedMessage, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT id, payload, created_at
+		 FROM queued_messages
+		 WHERE address = $1
+		 ORDER BY id`, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch queued messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []types.QueuedMessage{}
+	for rows.Next() {
+		var m types.QueuedMessage
+		if err := rows.Scan(&m.ID, &m.Payload, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan queued message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating queued messages: %w", err)
+	}
+	return messages, nil
+}
+
+// AckMessages removes acknowledged messages from the queue of an address.
+func (c *CRUD) AckMessages(address string, ids []int64) (int64, error) {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		"DELETE FROM queued_messages WHERE address = $1 AND id = ANY($2)",
+		address, ids,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acknowledge messages: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// PruneQueuedMessages deletes queued messages older than the retention window.
+func (c *CRUD) PruneQueuedMessages(retention time.Duration) (int64, error) {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		"DELETE FROM queued_messages WHERE created_at < $1",
+		time.Now().Add(-retention),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune queued messages: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
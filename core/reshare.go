@@ -0,0 +1,293 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrResharing         = errors.New("the organization's key shares are being reshared, transactions are blocked until the reshare finishes")
+	ErrReshareRequired   = errors.New("the organization's members changed, transactions are blocked until its key is reshared")
+	ErrStaleKeyEpoch     = errors.New("transaction belongs to an earlier key epoch of the organization")
+	ErrReshareInProgress = errors.New("a reshare of the organization's key is already running")
+	ErrSigningInProgress = errors.New("a transaction of the organization is being signed")
+)
+
+// checkNotResharing refuses to let a transaction progress while the key shares
+// of its organization are being replaced: until the reshare finishes, pending
+// transactions belong to the previous epoch. The organization row is share
+// locked, so a reshare cannot begin before the caller commits.
+func checkNotResharing(tx pgx.Tx, orgID int) error {
+	var resharing bool
+	err := tx.QueryRow(
+		context.Background(),
+		`SELECT reshare_session IS NOT NULL FROM organizations WHERE id = $1 FOR SHARE`, orgID,
+	).Scan(&resharing)
+	if err != nil {
+		return fmt.Errorf("failed to check organization reshare: %w", err)
+	}
+	if resharing {
+		return ErrResharing
+	}
+	return nil
+}
+
+// checkKeyEpoch refuses to let a transaction be approved or signed unless the
+// shares of the organization's current key epoch can sign it: not while a
+// reshare runs, not while one is required because participants left or the
+// threshold changed, and not for a transaction of an earlier epoch. Membership
+// proposals do not involve the key and are only held back by a running
+// reshare, since they may be what makes a required reshare possible. The
+// organization row is share locked like in checkNotResharing.
+func checkKeyEpoch(tx pgx.Tx, t types.Transaction) error {
+	var resharing, required bool
+	var epoch int
+	err := tx.QueryRow(
+		context.Background(),
+		`SELECT reshare_session IS NOT NULL, reshare_required, key_epoch FROM organizations WHERE id = $1 FOR SHARE`,
+		t.OrganizationID,
+	).Scan(&resharing, &required, &epoch)
+	if err != nil {
+		return fmt.Errorf("failed to check organization key epoch: %w", err)
+	}
+	switch {
+	case resharing:
+		return ErrResharing
+	case t.Payload.Membership != nil:
+		return nil
+	case required:
+		return ErrReshareRequired
+	case t.KeyEpoch != epoch:
+		return fmt.Errorf("%w: transaction %d is of epoch %d, the organization is at epoch %d", ErrStaleKeyEpoch, t.ID, t.KeyEpoch, epoch)
+	}
+	return nil
+}
+
+// carryOverTransactions moves the transactions of an organization that are
+// still waiting for approval or signing to a new key epoch.
+func carryOverTransactions(tx pgx.Tx, orgID, epoch int) error {
+	_, err := tx.Exec(
+		context.Background(),
+		`UPDATE transactions SET key_epoch = $2 WHERE organization_id = $1 AND status IN ($3, $4)`,
+		orgID, epoch, types.TransactionPending, types.TransactionApproved,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to carry over transactions: %w", err)
+	}
+	return nil
+}
+
+// BeginReshare stores a reshare session about to start and marks it as the running
+// reshare of its organization, which blocks the organization's transactions
+// until the session completes or aborts. Only one reshare runs at a time, and
+// none while a transaction is being signed with the current shares.
+func (c *CRUD) BeginReshare(s types.CeremonySession) error {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	var running string
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT COALESCE(reshare_session, '') FROM organizations WHERE id = $1 FOR UPDATE`, s.OrganizationID,
+	).Scan(&running)
+	if err != nil {
+		return fmt.Errorf("failed to lock organization: %w", err)
+	}
+	if running != "" {
+		return ErrReshareInProgress
+	}
+
+	var signing bool
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT EXISTS (SELECT 1 FROM transactions WHERE organization_id = $1 AND status = $2)`,
+		s.OrganizationID, types.TransactionSigning,
+	).Scan(&signing)
+	if err != nil {
+		return fmt.Errorf("failed to check signing transactions: %w", err)
+	}
+	if signing {
+		return ErrSigningInProgress
+	}
+
+	if err := saveCeremony(tx, s); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`UPDATE organizations SET reshare_session = $2 WHERE id = $1`, s.OrganizationID, s.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark reshare: %w", err)
+	}
+
+	return tx.Commit(context.Background())
+}
+
+// CompleteReshare stores the finished reshare session and starts the next key
+// epoch: the new participants of the session become the share holders, pending
+// transactions are carried over to the new epoch and the organization is told
+// through the outbox. A reshare is still required afterwards if participants
+// joined while the session ran.
+func (c *CRUD) CompleteReshare(s types.CeremonySession) (types.KeyReshared, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.KeyReshared{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	var running string
+	var threshold int
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT COALESCE(reshare_session, ''), threshold FROM organizations WHERE id = $1 FOR UPDATE`, s.OrganizationID,
+	).Scan(&running, &threshold)
+	if err != nil {
+		return types.KeyReshared{}, fmt.Errorf("failed to lock organization: %w", err)
+	}
+	if running != s.ID {
+		return types.KeyReshared{}, fmt.Errorf("reshare session %s is not the running reshare of organization %d", s.ID, s.OrganizationID)
+	}
+
+	if err := saveCeremony(tx, s); err != nil {
+		return types.KeyReshared{}, err
+	}
+
+	rows, err := tx.Query(
+		context.Background(),
+		`SELECT address FROM participants WHERE organization_id = $1`, s.OrganizationID)
+	if err != nil {
+		return types.KeyReshared{}, fmt.Errorf("failed to fetch participants: %w", err)
+	}
+	participants, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return types.KeyReshared{}, fmt.Errorf("failed to scan participants: %w", err)
+	}
+	holder := make(map[string]bool, len(s.NewParticipants))
+	for _, addr := range s.NewParticipants {
+		holder[addr] = true
+	}
+	required := len(participants) != len(s.NewParticipants)
+	for _, addr := range participants {
+		if !holder[addr] {
+			required = true
+		}
+	}
+
+	reshared := types.KeyReshared{
+		OrganizationID: s.OrganizationID,
+		SessionID:      s.ID,
+		Participants:   s.NewParticipants,
+		Threshold:      threshold,
+		Message:        "Key shares refreshed",
+	}
+	err = tx.QueryRow(
+		context.Background(),
+		`UPDATE organizations
+		 SET key_epoch = key_epoch + 1, share_holders = $2, share_threshold = threshold,
+		     reshare_required = $3, reshare_session = NULL, key_refreshed_at = NOW()
+		 WHERE id = $1
+		 RETURNING key_epoch`,
+		s.OrganizationID, s.NewParticipants, required,
+	).Scan(&reshared.KeyEpoch)
+	if err != nil {
+		return reshared, fmt.Errorf("failed to start key epoch: %w", err)
+	}
+
+	_, err = tx.Exec(
+		context.Background(),
+		`UPDATE organization_keys SET epoch = $2 WHERE organization_id = $1`,
+		s.OrganizationID, reshared.KeyEpoch,
+	)
+	if err != nil {
+		return reshared, fmt.Errorf("failed to update key epoch: %w", err)
+	}
+	if err := carryOverTransactions(tx, s.OrganizationID, reshared.KeyEpoch); err != nil {
+		return reshared, err
+	}
+	if err := recordEvent(tx, s.OrganizationID, types.EventKeyReshared, s.Initiator, reshared); err != nil {
+		return reshared, err
+	}
+
+	return reshared, tx.Commit(context.Background())
+}
+
+// AbortReshare stores the aborted reshare session and unblocks the
+// organization's transactions. The shares of the current epoch stay in use.
+func (c *CRUD) AbortReshare(s types.CeremonySession) error {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background())
+
+	if err := saveCeremony(tx, s); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		context.Background(),
+		`UPDATE organizations SET reshare_session = NULL WHERE id = $1 AND reshare_session = $2`,
+		s.OrganizationID, s.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to clear reshare: %w", err)
+	}
+
+	return tx.Commit(context.Background())
+}
+
+// ReleaseStaleReshares aborts reshares whose session has not moved for
+// staleAfter, which happens when the instance running it went away, so the
+// organization's transactions are not blocked forever.
+func (c *CRUD) ReleaseStaleReshares(staleAfter time.Duration) (int64, error) {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		`WITH stale AS (
+		   UPDATE organizations o SET reshare_session = NULL
+		   FROM ceremony_sessions c
+		   WHERE c.id = o.reshare_session AND c.updated_at < $1
+		   RETURNING c.id
+		 )
+		 UPDATE ceremony_sessions
+		 SET status = $2, reason = 'session was lost', updated_at = NOW()
+		 WHERE id IN (SELECT id FROM stale) AND status = $3`,
+		time.Now().Add(-staleAfter), types.CeremonyAborted, types.CeremonyRunning,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release stale reshares: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetDueReshares returns the organizations with a group key whose shares have
+// to be dealt anew, either because their participants changed or because the
+// shares are older than refreshInterval. A zero interval disables periodic refreshes.
+func (c *CRUD) GetDueReshares(refreshInterval time.Duration) ([]int, error) {
+	var refreshBefore *time.Time
+	if refreshInterval > 0 {
+		t := time.Now().Add(-refreshInterval)
+		refreshBefore = &t
+	}
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT id FROM organizations
+		 WHERE group_public_key IS NOT NULL AND reshare_session IS NULL
+		   AND (reshare_required OR key_refreshed_at <= $1)
+		 ORDER BY id`, refreshBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch due reshares: %w", err)
+	}
+	due, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan due reshares: %w", err)
+	}
+	return due, nil
+}
@@ -0,0 +1,471 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrTransactionNotFound  = errors.New("transaction not found")
+	ErrIllegalTransition    = errors.New("illegal transaction state transition")
+	ErrNotParticipant       = errors.New("address is not a participant of the organization")
+	ErrAlreadyConfirmed     = errors.New("address has already approved or rejected this transaction")
+	ErrDuplicateTransaction = errors.New("a transaction with the same payload is already in flight")
+)
+
+// Decisions a participant can record on a pending transaction.
+const (
+	decisionApprove = "approve"
+	decisionReject  = "reject"
+)
+
+// uniqueViolation is the Postgres error code raised when a unique constraint is violated.
+const uniqueViolation = "23505"
+
+// transactionTransitions lists the states each state is allowed to move to.
+// States without an entry are terminal.
+var transactionTransitions = map[types.TransactionStatus][]types.TransactionStatus{
+	types.TransactionPending: {
+		types.TransactionApproved,
+		types.TransactionRejected,
+		types.TransactionExpired,
+		types.TransactionCancelled,
+	},
+	types.TransactionApproved: {
+		types.TransactionSigning,
+		types.TransactionCancelled,
+		types.TransactionApplied,
+	},
+	types.TransactionSigning: {
+		types.TransactionSigned,
+		types.TransactionApproved,
+	},
+	types.TransactionSigned: {
+		types.TransactionBroadcast,
+	},
+}
+
+// CanTransition reports whether a transaction may move from one state to another.
+func CanTransition(from, to types.TransactionStatus) bool {
+	for _, next := range transactionTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+const transactionColumns = `id, organization_id, initiator, details, account_id, derivation_path, payload, payload_hash, signature, status, threshold, confirmations, key_epoch, created_at, updated_at, expires_at`
+
+func scanTransaction(row pgx.Row) (types.Transaction, error) {
+	var t types.Transaction
+	err := row.Scan(
+		&t.ID, &t.OrganizationID, &t.Initiator, &t.Details, &t.AccountID, &t.DerivationPath, &t.Payload, &t.PayloadHash, &t.Signature, &t.Status,
+		&t.Threshold, &t.Confirmations, &t.KeyEpoch, &t.CreatedAt, &t.UpdatedAt, &t.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return t, ErrTransactionNotFound
+	}
+	return t, err
+}
+
+// CreateTransaction stores a new pending transaction for an organization. The payload
+// must already be normalized and hashed, and the account, if any, must belong to the
+// organization. The transaction belongs to the organization's current key epoch.
+func (c *CRUD) CreateTransaction(t types.Transaction) (types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	t, err = scanTransaction(tx.QueryRow(
+		context.Background(),
+		`INSERT INTO transactions (organization_id, initiator, details, payload, payload_hash, status, threshold, expires_at, account_id, derivation_path, key_epoch)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, (SELECT key_epoch FROM organizations WHERE id = $1))
+		 RETURNING `+transactionColumns,
+		t.OrganizationID, t.Initiator, t.Details, t.Payload, t.PayloadHash, types.TransactionPending, t.Threshold, t.ExpiresAt, t.AccountID, t.DerivationPath,
+	))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			if pgErr.ConstraintName == membershipPendingIndex {
+				return t, ErrProposalInFlight
+			}
+			return t, ErrDuplicateTransaction
+		}
+		return t, fmt.Errorf("failed to insert transaction: %w", err)
+	}
+
+	if err := recordStatusChange(tx, t.ID, nil, t.Status, t.Initiator); err != nil {
+		return t, err
+	}
+	if err := recordEvent(tx, t.OrganizationID, types.EventTransactionInitiated, t.Initiator, t); err != nil {
+		return t, err
+	}
+
+	return t, tx.Commit(context.Background())
+}
+
+// GetTransaction fetches a single transaction by its ID.
+func (c *CRUD) GetTransaction(id int) (types.Transaction, error) {
+	return scanTransaction(c.Connection.QueryRow(
+		context.Background(),
+		`SELECT `+transactionColumns+` FROM transactions WHERE id = $1`, id,
+	))
+}
+
+// GetTransactionsByOrganization returns every transaction an organization ever proposed, newest first.
+func (c *CRUD) GetTransactionsByOrganization(orgID int) ([]types.Transaction, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT `+transactionColumns+`
+		 FROM transactions
+		 WHERE organization_id = $1
+		 ORDER BY created_at DESC, id DESC`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions := []types.Transaction{}
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		transactions = append(transactions, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// GetTransactionHistory returns the audit trail of a transaction in chronological order.
+func (c *CRUD) GetTransactionHistory(id int) ([]types.TransactionStatusChange, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT from_status, to_status, actor, created_at
+		 FROM transaction_status_history
+		 WHERE transaction_id = $1
+		 ORDER BY created_at, id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []types.TransactionStatusChange{}
+	for rows.Next() {
+		var change types.TransactionStatusChange
+		if err := rows.Scan(&change.From, &change.To, &change.Actor, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction history: %w", err)
+		}
+		history = append(history, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transaction history: %w", err)
+	}
+	return history, nil
+}
+
+// GetTransactionApprovals returns the approvals recorded for a transaction in the order they were given.
+func (c *CRUD) GetTransactionApprovals(id int) ([]types.Approval, error) {
+	return c.getDecisions(id, decisionApprove)
+}
+
+// GetTransactionRejections returns the rejections recorded for a transaction in the order they were given.
+func (c *CRUD) GetTransactionRejections(id int) ([]types.Approval, error) {
+	return c.getDecisions(id, decisionReject)
+}
+
+func (c *CRUD) getDecisions(id int, decision string) ([]types.Approval, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT address, signature, scheme, created_at
+		 FROM transaction_approvals
+		 WHERE transaction_id = $1 AND decision = $2
+		 ORDER BY created_at, id`, id, decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction approvals: %w", err)
+	}
+	defer rows.Close()
+
+	approvals := []types.Approval{}
+	for rows.Next() {
+		var a types.Approval
+		if err := rows.Scan(&a.Address, &a.Signature, &a.Scheme, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transaction approval: %w", err)
+		}
+		approvals = append(approvals, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transaction approvals: %w", err)
+	}
+	return approvals, nil
+}
+
+// ConfirmTransaction records the signed approval of a participant on a pending transaction and
+// moves it to approved once the threshold is reached. Each participant can approve once.
+// The signature must already have been verified against the transaction's payload hash.
+// An approved membership proposal is applied in the same database transaction, inviting
+// added addresses until inviteExpiresAt.
+func (c *CRUD) ConfirmTransaction(id int, address, signature, scheme string, inviteExpiresAt time.Time) (types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	t, err := recordDecision(tx, id, address, signature, scheme, decisionApprove)
+	if err != nil {
+		return t, err
+	}
+
+	t, err = scanTransaction(tx.QueryRow(
+		context.Background(),
+		`UPDATE transactions
+		 SET confirmations = confirmations + 1, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+transactionColumns, id,
+	))
+	if err != nil {
+		return t, fmt.Errorf("failed to confirm transaction: %w", err)
+	}
+	if err := recordEvent(tx, t.OrganizationID, types.EventTransactionApproval, address, t); err != nil {
+		return t, err
+	}
+
+	if t.Confirmations >= t.Threshold {
+		t, err = transition(tx, t, types.TransactionApproved, address)
+		if err != nil {
+			return t, err
+		}
+
+		if t.Payload.Membership != nil {
+			if err := applyMembership(tx, t, inviteExpiresAt); err != nil {
+				return t, err
+			}
+			t, err = transition(tx, t, types.TransactionApplied, address)
+			if err != nil {
+				return t, err
+			}
+		}
+	}
+
+	return t, tx.Commit(context.Background())
+}
+
+// RejectTransaction records the signed rejection of a participant on a pending transaction and
+// moves it to rejected once too few participants are left to reach the threshold. A participant
+// either approves or rejects a transaction, never both. The signature must already have been verified.
+func (c *CRUD) RejectTransaction(id int, address, signature, scheme string) (types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	t, err := recordDecision(tx, id, address, signature, scheme, decisionReject)
+	if err != nil {
+		return t, err
+	}
+
+	var participants, rejections int
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT
+		   (SELECT COUNT(*) FROM participants WHERE organization_id = $1),
+		   (SELECT COUNT(*) FROM transaction_approvals WHERE transaction_id = $2 AND decision = $3)`,
+		t.OrganizationID, id, decisionReject,
+	).Scan(&participants, &rejections)
+	if err != nil {
+		return t, fmt.Errorf("failed to count rejections: %w", err)
+	}
+	if err := recordEvent(tx, t.OrganizationID, types.EventTransactionRejection, address, t); err != nil {
+		return t, err
+	}
+
+	if participants-rejections < t.Threshold {
+		t, err = transition(tx, t, types.TransactionRejected, address)
+		if err != nil {
+			return t, err
+		}
+	}
+
+	return t, tx.Commit(context.Background())
+}
+
+// TransitionTransaction moves a transaction to a new state, refusing illegal jumps.
+func (c *CRUD) TransitionTransaction(id int, to types.TransactionStatus, actor string) (types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	defer tx.Rollback(context.Background())
+
+	t, err := lockTransaction(tx, id)
+	if err != nil {
+		return t, err
+	}
+
+	t, err = transition(tx, t, to, actor)
+	if err != nil {
+		return t, err
+	}
+
+	return t, tx.Commit(context.Background())
+}
+
+// ExpireTransactions moves every pending transaction past its expiry to expired.
+func (c *CRUD) ExpireTransactions() ([]types.Transaction, error) {
+	tx, err := c.Connection.Begin(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(context.Background())
+
+	rows, err := tx.Query(
+		context.Background(),
+		`SELECT `+transactionColumns+`
+		 FROM transactions
+		 WHERE status = $1 AND expires_at <= NOW()
+		 FOR UPDATE SKIP LOCKED`, types.TransactionPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch expired transactions: %w", err)
+	}
+
+	var stale []types.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan transaction: %w", err)
+		}
+		stale = append(stale, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating transactions: %w", err)
+	}
+
+	expired := make([]types.Transaction, 0, len(stale))
+	for _, t := range stale {
+		t, err = transition(tx, t, types.TransactionExpired, "")
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, t)
+	}
+
+	return expired, tx.Commit(context.Background())
+}
+
+// recordDecision locks a pending transaction and stores the approval or rejection of a participant.
+func recordDecision(tx pgx.Tx, id int, address, signature, scheme, decision string) (types.Transaction, error) {
+	t, err := lockTransaction(tx, id)
+	if err != nil {
+		return t, err
+	}
+	if t.Status != types.TransactionPending {
+		return t, fmt.Errorf("%w: cannot %s a %s transaction", ErrIllegalTransition, decision, t.Status)
+	}
+	// Rejecting never leads to signing, so only a running reshare holds it back.
+	if decision == decisionApprove {
+		err = checkKeyEpoch(tx, t)
+	} else {
+		err = checkNotResharing(tx, t.OrganizationID)
+	}
+	if err != nil {
+		return t, err
+	}
+
+	var member bool
+	err = tx.QueryRow(
+		context.Background(),
+		`SELECT EXISTS (SELECT 1 FROM participants WHERE organization_id = $1 AND address = $2)`,
+		t.OrganizationID, address,
+	).Scan(&member)
+	if err != nil {
+		return t, fmt.Errorf("failed to check participant: %w", err)
+	}
+	if !member {
+		return t, ErrNotParticipant
+	}
+
+	_, err = tx.Exec(
+		context.Background(),
+		`INSERT INTO transaction_approvals (transaction_id, address, signature, scheme, decision) VALUES ($1, $2, $3, $4, $5)`,
+		id, address, signature, scheme, decision,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return t, ErrAlreadyConfirmed
+		}
+		return t, fmt.Errorf("failed to record %s decision: %w", decision, err)
+	}
+	return t, nil
+}
+
+func lockTransaction(tx pgx.Tx, id int) (types.Transaction, error) {
+	return scanTransaction(tx.QueryRow(
+		context.Background(),
+		`SELECT `+transactionColumns+` FROM transactions WHERE id = $1 FOR UPDATE`, id,
+	))
+}
+
+// transition updates the status of a locked transaction and records it in the
+// audit trail and, for transitions worth announcing, in the outbox.
+func transition(tx pgx.Tx, t types.Transaction, to types.TransactionStatus, actor string) (types.Transaction, error) {
+	if !CanTransition(t.Status, to) {
+		return t, fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, t.Status, to)
+	}
+	if to == types.TransactionSigning {
+		if err := checkKeyEpoch(tx, t); err != nil {
+			return t, err
+		}
+	}
+
+	from := t.Status
+	updated, err := scanTransaction(tx.QueryRow(
+		context.Background(),
+		`UPDATE transactions
+		 SET status = $2, updated_at = NOW()
+		 WHERE id = $1
+		 RETURNING `+transactionColumns, t.ID, to,
+	))
+	if err != nil {
+		return t, fmt.Errorf("failed to update transaction status: %w", err)
+	}
+
+	if err := recordStatusChange(tx, t.ID, &from, to, actor); err != nil {
+		return t, err
+	}
+	if event := transitionEvent(from, to); event != "" {
+		if err := recordEvent(tx, t.OrganizationID, event, actor, updated); err != nil {
+			return t, err
+		}
+	}
+
+	return updated, nil
+}
+
+func recordStatusChange(tx pgx.Tx, id int, from *types.TransactionStatus, to types.TransactionStatus, actor string) error {
+	_, err := tx.Exec(
+		context.Background(),
+		`INSERT INTO transaction_status_history (transaction_id, from_status, to_status, actor)
+		 VALUES ($1, $2, $3, $4)`,
+		id, from, to, actor,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record transaction status change: %w", err)
+	}
+	return nil
+}
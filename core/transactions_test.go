@@ -0,0 +1,46 @@
+package crud
+
+import (
+	"mpc-backend/types"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []types.TransactionStatus{
+		types.TransactionPending,
+		types.TransactionApproved,
+		types.TransactionSigning,
+		types.TransactionSigned,
+		types.TransactionBroadcast,
+		types.TransactionRejected,
+		types.TransactionExpired,
+		types.TransactionCancelled,
+		types.TransactionApplied,
+	}
+	allowed := map[types.TransactionStatus][]types.TransactionStatus{
+		types.TransactionPending:   {types.TransactionApproved, types.TransactionRejected, types.TransactionExpired, types.TransactionCancelled},
+		types.TransactionApproved:  {types.TransactionSigning, types.TransactionCancelled, types.TransactionApplied},
+		types.TransactionSigning:   {types.TransactionSigned, types.TransactionApproved},
+		types.TransactionSigned:    {types.TransactionBroadcast},
+		types.TransactionBroadcast: nil,
+		types.TransactionRejected:  nil,
+		types.TransactionExpired:   nil,
+		types.TransactionCancelled: nil,
+		types.TransactionApplied:   nil,
+	}
+
+	for _, from := range statuses {
+		want := make(map[types.TransactionStatus]bool)
+		for _, to := range allowed[from] {
+			want[to] = true
+		}
+		for _, to := range statuses {
+			if got := CanTransition(from, to); got != want[to] {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want[to])
+			}
+		}
+	}
+	if CanTransition("unknown", types.TransactionPending) {
+		t.Error("an unknown state may transition")
+	}
+}
@@ -0,0 +1,237 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+const webhookColumns = `id, organization_id, url, events, active, created_by, created_at, updated_at`
+
+func scanWebhook(row pgx.Row) (types.Webhook, error) {
+	var w types.Webhook
+	err := row.Scan(&w.ID, &w.OrganizationID, &w.URL, &w.Events, &w.Active, &w.CreatedBy, &w.CreatedAt, &w.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return w, ErrWebhookNotFound
+	}
+	return w, err
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+func scanWebhookDelivery(row pgx.Row) (types.WebhookDelivery, error) {
+	var d types.WebhookDelivery
+	err := row.Scan(
+		&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return d, ErrWebhookDeliveryNotFound
+	}
+	return d, err
+}
+
+// CreateWebhook stores a new webhook subscription of an organization.
+func (c *CRUD) CreateWebhook(w types.Webhook) (types.Webhook, error) {
+	created, err := scanWebhook(c.Connection.QueryRow(
+		context.Background(),
+		`INSERT INTO webhooks (organization_id, url, events, secret, active, created_by)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+webhookColumns,
+		w.OrganizationID, w.URL, w.Events, w.Secret, w.Active, w.CreatedBy,
+	))
+	if err != nil {
+		return created, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	created.Secret = w.Secret
+	return created, nil
+}
+
+// GetWebhooks lists the webhooks of an organization without their secrets.
+func (c *CRUD) GetWebhooks(orgID int) ([]types.Webhook, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT `+webhookColumns+` FROM webhooks WHERE organization_id = $1 ORDER BY id`, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []types.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook fetches a webhook of an organization without its secret.
+func (c *CRUD) GetWebhook(orgID, id int) (types.Webhook, error) {
+	return scanWebhook(c.Connection.QueryRow(
+		context.Background(),
+		`SELECT `+webhookColumns+` FROM webhooks WHERE organization_id = $1 AND id = $2`, orgID, id,
+	))
+}
+
+// UpdateWebhook replaces the URL, event filter and active flag of a webhook.
+func (c *CRUD) UpdateWebhook(orgID, id int, url string, events []string, active bool) (types.Webhook, error) {
+	return scanWebhook(c.Connection.QueryRow(
+		context.Background(),
+		`UPDATE webhooks SET url = $3, events = $4, active = $5, updated_at = NOW()
+		 WHERE organization_id = $1 AND id = $2
+		 RETURNING `+webhookColumns,
+		orgID, id, url, events, active,
+	))
+}
+
+// DeleteWebhook removes a webhook together with its delivery log.
+func (c *CRUD) DeleteWebhook(orgID, id int) error {
+	tag, err := c.Connection.Exec(
+		context.Background(),
+		`DELETE FROM webhooks WHERE organization_id = $1 AND id = $2`, orgID, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnqueueWebhookEvent schedules a delivery of the outbox event to every active
+// webhook of the organization whose filter matches it. Enqueueing the same
+// outbox event again does not duplicate deliveries.
+func (c *CRUD) EnqueueWebhookEvent(orgID int, outboxID int64, event string, payload []byte) error {
+	_, err := c.Connection.Exec(
+		context.Background(),
+		`INSERT INTO webhook_deliveries (webhook_id, event, payload, outbox_id)
+		 SELECT id, $2, $3, $4 FROM webhooks
+		 WHERE organization_id = $1 AND active AND (cardinality(events) = 0 OR $2 = ANY(events))
+		 ON CONFLICT DO NOTHING`,
+		orgID, event, payload, outboxID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook, newest first.
+// An empty status returns deliveries in every state.
+func (c *CRUD) GetWebhookDeliveries(webhookID int, status types.WebhookDeliveryStatus, limit int) ([]types.WebhookDelivery, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`SELECT `+webhookDeliveryColumns+`
+		 FROM webhook_deliveries
+		 WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		 ORDER BY id DESC
+		 LIMIT $3`, webhookID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []types.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// RedeliverWebhookDelivery schedules a delivery to be sent again right away
+// with a fresh set of attempts, whatever its current state.
+func (c *CRUD) RedeliverWebhookDelivery(webhookID int, id int64) (types.WebhookDelivery, error) {
+	return scanWebhookDelivery(c.Connection.QueryRow(
+		context.Background(),
+		`UPDATE webhook_deliveries
+		 SET status = $3, attempts = 0, next_attempt_at = NOW(), last_error = ''
+		 WHERE webhook_id = $1 AND id = $2
+		 RETURNING `+webhookDeliveryColumns,
+		webhookID, id, types.WebhookDeliveryPending,
+	))
+}
+
+// ClaimWebhookDeliveries picks up to limit due deliveries and pushes their next
+// attempt back by lease, so other instances leave them alone while they are sent.
+// Deliveries of inactive webhooks stay pending until the webhook is reactivated.
+func (c *CRUD) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]types.PendingWebhookDelivery, error) {
+	rows, err := c.Connection.Query(
+		context.Background(),
+		`WITH due AS (
+		   SELECT d.id FROM webhook_deliveries d
+		   JOIN webhooks w ON w.id = d.webhook_id
+		   WHERE d.status = $1 AND d.next_attempt_at <= NOW() AND w.active
+		   ORDER BY d.next_attempt_at, d.id
+		   LIMIT $2
+		   FOR UPDATE OF d SKIP LOCKED
+		 )
+		 UPDATE webhook_deliveries d SET next_attempt_at = $3
+		 FROM due, webhooks w
+		 WHERE d.id = due.id AND w.id = d.webhook_id
+		 RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+		           d.last_status_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret`,
+		types.WebhookDeliveryPending, limit, time.Now().Add(lease),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []types.PendingWebhookDelivery
+	for rows.Next() {
+		var p types.PendingWebhookDelivery
+		d := &p.WebhookDelivery
+		err := rows.Scan(
+			&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt, &p.URL, &p.Secret,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		claimed = append(claimed, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+	return claimed, nil
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt. A pending
+// status schedules the next attempt at next.
+func (c *CRUD) RecordWebhookAttempt(id int64, status types.WebhookDeliveryStatus, statusCode int, lastError string, next time.Time) error {
+	_, err := c.Connection.Exec(
+		context.Background(),
+		`UPDATE webhook_deliveries
+		 SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4, next_attempt_at = $5,
+		     delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() ELSE delivered_at END
+		 WHERE id = $1`,
+		id, status, statusCode, lastError, next,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"mpc-backend/validation"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// addressesQuery collects every address stored where it is looked up by the
+// session address. Audit trails, ceremony history and stored payloads keep the
+// spelling they were written with.
+const addressesQuery = `
+	SELECT address FROM participants
+	UNION SELECT address FROM invitations
+	UNION SELECT invited_by FROM invitations
+	UNION SELECT admin FROM organizations WHERE admin IS NOT NULL
+	UNION SELECT unnest(share_holders) FROM organizations
+	UNION SELECT initiator FROM transactions
+	UNION SELECT address FROM transaction_approvals
+	UNION SELECT address FROM encryption_keys
+	UNION SELECT address FROM queued_messages
+	UNION SELECT address FROM sessions`
+
+// mergedTables lists the tables where two spellings of an address can clash
+// once they are made canonical, with the row to keep of each clash.
+var mergedTables = []struct {
+	table     string
+	key       string
+	partition string
+	keep      string
+}{
+	{"participants", "id", "organization_id", "id"},
+	// An accepted invitation makes its address a participant, so it wins.
+	{"invitations", "id", "organization_id", "status = 'accepted' DESC, id DESC"},
+	// The first decision on a transaction counts, as for a single spelling.
+	{"transaction_approvals", "id", "transaction_id", "id"},
+	{"encryption_keys", "address", "TRUE", "updated_at DESC"},
+	{"queued_messages", "id", "COALESCE(outbox_id, id)", "id"},
+}
+
+// renamedColumns lists every column rewritten to the canonical spelling.
+var renamedColumns = []struct {
+	table  string
+	column string
+}{
+	{"participants", "address"},
+	{"invitations", "address"},
+	{"invitations", "invited_by"},
+	{"organizations", "admin"},
+	{"transactions", "initiator"},
+	{"transaction_approvals", "address"},
+	{"encryption_keys", "address"},
+	{"queued_messages", "address"},
+	{"sessions", "address"},
+}
+
+// checksumAddresses rewrites stored addresses to the canonical spelling sessions
+// carry since logins are normalized: EIP-55 for EVM addresses, which SQL cannot
+// compute, and lower case hex for ed25519 keys. Rows that only differed in the
+// spelling of their address are merged.
+func checksumAddresses(ctx context.Context, tx pgx.Tx) error {
+	rows, err := tx.Query(ctx, addressesQuery)
+	if err != nil {
+		return fmt.Errorf("failed to fetch addresses: %w", err)
+	}
+	stored, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to scan addresses: %w", err)
+	}
+
+	old, canonical := canonicalAddresses(stored, validation.NewAddresses())
+	if len(old) == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `CREATE TEMPORARY TABLE address_map (old TEXT PRIMARY KEY, new TEXT NOT NULL) ON COMMIT DROP`)
+	if err != nil {
+		return fmt.Errorf("failed to create address map: %w", err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO address_map (old, new) SELECT * FROM unnest($1::text[], $2::text[])`, old, canonical)
+	if err != nil {
+		return fmt.Errorf("failed to fill address map: %w", err)
+	}
+
+	for _, t := range mergedTables {
+		if err := mergeAddresses(ctx, tx, t.table, t.key, t.partition, t.keep); err != nil {
+			return err
+		}
+	}
+	for _, c := range renamedColumns {
+		_, err := tx.Exec(ctx, fmt.Sprintf(
+			`UPDATE %[1]s t SET %[2]s = m.new FROM address_map m WHERE t.%[2]s = m.old`, c.table, c.column))
+		if err != nil {
+			return fmt.Errorf("failed to rewrite %s.%s: %w", c.table, c.column, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE organizations o
+		SET share_holders = ARRAY(
+		    SELECT DISTINCT COALESCE(m.new, h) FROM unnest(o.share_holders) h
+		    LEFT JOIN address_map m ON m.old = h
+		    ORDER BY 1)
+		WHERE o.share_holders && ARRAY(SELECT old FROM address_map)`)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite share holders: %w", err)
+	}
+	return nil
+}
+
+// mergeAddresses deletes all but one of the rows of a table that share the
+// partition and the canonical spelling of their address. Approvals that are
+// dropped no longer count towards their transaction.
+func mergeAddresses(ctx context.Context, tx pgx.Tx, table, key, partition, keep string) error {
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+		WITH ranked AS (
+		    SELECT t.%[2]s AS key, ROW_NUMBER() OVER (
+		        PARTITION BY %[3]s, COALESCE(m.new, t.address) ORDER BY %[4]s) AS n
+		    FROM %[1]s t LEFT JOIN address_map m ON m.old = t.address
+		)
+		DELETE FROM %[1]s WHERE %[2]s IN (SELECT key FROM ranked WHERE n > 1)
+		RETURNING %[5]s`, table, key, partition, keep, returnedColumn(table)))
+	if err != nil {
+		return fmt.Errorf("failed to merge addresses of %s: %w", table, err)
+	}
+	dropped, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to merge addresses of %s: %w", table, err)
+	}
+	if table != "transaction_approvals" || len(dropped) == 0 {
+		return nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE transactions t
+		SET confirmations = (SELECT COUNT(*) FROM transaction_approvals a WHERE a.transaction_id = t.id AND a.decision = 'approve')
+		WHERE t.id::text = ANY($1)`, dropped)
+	if err != nil {
+		return fmt.Errorf("failed to recount confirmations: %w", err)
+	}
+	return nil
+}
+
+// returnedColumn is what mergeAddresses reports about deleted rows: the
+// transaction of a dropped approval, otherwise just the table's key.
+func returnedColumn(table string) string {
+	if table == "transaction_approvals" {
+		return "transaction_id::text"
+	}
+	if table == "encryption_keys" {
+		return "address"
+	}
+	return "id::text"
+}
+
+// canonicalAddresses returns the stored addresses whose spelling is not
+// canonical, together with their canonical spelling. Values that are not
+// addresses in a known format are left alone.
+func canonicalAddresses(stored []string, addresses *validation.Addresses) ([]string, []string) {
+	var old, canonical []string
+	for _, s := range stored {
+		normalized, err := addresses.Normalize(s)
+		if err != nil || normalized == s {
+			continue
+		}
+		old = append(old, s)
+		canonical = append(canonical, normalized)
+	}
+	return old, canonical
+}
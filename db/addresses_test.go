@@ -0,0 +1,42 @@
+package db
+
+import (
+	"mpc-backend/validation"
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalAddresses(t *testing.T) {
+	stored := []string{
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		" 0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xD1220A0CF47C7B9BE7A2E6BA89F429762E7B9ADB",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0x5AAEB6053f3e94c9b9a09f33669435e7ef1beaed",
+		"0XD6F4C1C8A1D0B1C8B5E8F0A1B2C3D4E5F60718293A4B5C6D7E8F90A1B2C3D4E5",
+		"d6f4c1c8a1d0b1c8b5e8f0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5",
+		"",
+		"not an address",
+	}
+	wantOld := []string{
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		" 0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xD1220A0CF47C7B9BE7A2E6BA89F429762E7B9ADB",
+		"0XD6F4C1C8A1D0B1C8B5E8F0A1B2C3D4E5F60718293A4B5C6D7E8F90A1B2C3D4E5",
+	}
+	wantCanonical := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+		"d6f4c1c8a1d0b1c8b5e8f0a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5",
+	}
+
+	old, canonical := canonicalAddresses(stored, validation.NewAddresses())
+	if !reflect.DeepEqual(old, wantOld) {
+		t.Errorf("old = %q, want %q", old, wantOld)
+	}
+	if !reflect.DeepEqual(canonical, wantCanonical) {
+		t.Errorf("canonical = %q, want %q", canonical, wantCanonical)
+	}
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+)
+
+// dataMigration changes stored data in a way SQL cannot express. It runs after
+// the schema migrations, once per database.
+type dataMigration struct {
+	name string
+	run  func(ctx context.Context, tx pgx.Tx) error
+}
+
+var dataMigrations = []dataMigration{
+	{name: "checksum_addresses", run: checksumAddresses},
+}
+
+func runDataMigrations(pool *pgxpool.Pool) error {
+	for _, m := range dataMigrations {
+		if err := runDataMigration(pool, m); err != nil {
+			return fmt.Errorf("data migration %s failed: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// runDataMigration runs a data migration and records it in the same
+// transaction. Instances starting at the same time wait on the record of the
+// first one and skip the migration once it committed.
+func runDataMigration(pool *pgxpool.Pool, m dataMigration) error {
+	ctx := context.Background()
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, m.name)
+	if err != nil {
+		return fmt.Errorf("failed to record data migration: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil
+	}
+
+	if err := m.run(ctx, tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	log.Info().Str("migration", m.name).Msg("Data migration applied")
+	return nil
+}
@@ -26,6 +26,11 @@ func NewMasterDb(config config.DbConfig) (*pgxpool.Pool, error) {
 		return nil, fmt.Errorf("could not run migrationas on master database: %v", err)
 	}
 
+	err = runDataMigrations(masterDb)
+	if err != nil {
+		return nil, fmt.Errorf("could not run data migrations on master database: %v", err)
+	}
+
 	return masterDb, nil
 }
 
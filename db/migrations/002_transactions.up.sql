@@ -0,0 +1,29 @@
+CREATE TABLE transactions (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL,
+    initiator VARCHAR(255) NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status VARCHAR(32) NOT NULL DEFAULT 'pending',
+    threshold INTEGER NOT NULL,
+    confirmations INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    CHECK (status IN ('pending', 'approved', 'signing', 'signed', 'broadcast', 'rejected', 'expired', 'cancelled'))
+);
+
+CREATE INDEX transactions_organization_id_idx ON transactions (organization_id, created_at);
+CREATE INDEX transactions_pending_expiry_idx ON transactions (expires_at) WHERE status = 'pending';
+
+CREATE TABLE transaction_status_history (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL,
+    from_status VARCHAR(32),
+    to_status VARCHAR(32) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX transaction_status_history_transaction_id_idx ON transaction_status_history (transaction_id);
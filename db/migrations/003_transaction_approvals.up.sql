@@ -0,0 +1,8 @@
+CREATE TABLE transaction_approvals (
+    id SERIAL PRIMARY KEY,
+    transaction_id INTEGER NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    UNIQUE (transaction_id, address)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE auth_challenges (
+    nonce VARCHAR(64) PRIMARY KEY,
+    address VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE sessions (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    address VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX sessions_expires_at_idx ON sessions (expires_at);
@@ -0,0 +1,4 @@
+ALTER TABLE transaction_approvals DROP COLUMN IF EXISTS scheme;
+ALTER TABLE transaction_approvals DROP COLUMN IF EXISTS signature;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS payload_hash;
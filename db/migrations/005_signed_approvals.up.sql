@@ -0,0 +1,4 @@
+ALTER TABLE transactions ADD COLUMN payload_hash VARCHAR(66) NOT NULL DEFAULT '';
+
+ALTER TABLE transaction_approvals ADD COLUMN signature TEXT NOT NULL DEFAULT '';
+ALTER TABLE transaction_approvals ADD COLUMN scheme VARCHAR(32) NOT NULL DEFAULT '';
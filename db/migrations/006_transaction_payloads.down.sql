@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS transactions_active_payload_hash_idx;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS payload;
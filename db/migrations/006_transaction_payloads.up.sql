@@ -0,0 +1,5 @@
+ALTER TABLE transactions ADD COLUMN payload JSONB NOT NULL DEFAULT '{}';
+
+-- The payload hash identifies a transaction; the same payload cannot be in flight twice.
+CREATE UNIQUE INDEX transactions_active_payload_hash_idx ON transactions (organization_id, payload_hash)
+    WHERE status IN ('pending', 'approved', 'signing');
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS ceremony_sessions;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS group_public_key;
+ALTER TABLE organizations DROP COLUMN IF EXISTS admin;
@@ -0,0 +1,25 @@
+ALTER TABLE organizations ADD COLUMN admin VARCHAR(255);
+ALTER TABLE organizations ADD COLUMN group_public_key TEXT;
+
+UPDATE organizations o SET admin = (
+    SELECT p.address FROM participants p WHERE p.organization_id = o.id ORDER BY p.id LIMIT 1
+);
+
+CREATE TABLE ceremony_sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    kind VARCHAR(32) NOT NULL,
+    organization_id INTEGER NOT NULL,
+    initiator VARCHAR(255) NOT NULL,
+    participants TEXT[] NOT NULL,
+    subject TEXT NOT NULL DEFAULT '',
+    status VARCHAR(32) NOT NULL,
+    round INTEGER NOT NULL,
+    result TEXT NOT NULL DEFAULT '',
+    blamed TEXT[] NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id)
+);
+
+CREATE INDEX ceremony_sessions_organization_id_idx ON ceremony_sessions (organization_id, created_at);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS ceremony_sessions_transaction_id_idx;
+
+ALTER TABLE ceremony_sessions DROP COLUMN IF EXISTS transaction_id;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS signature;
@@ -0,0 +1,5 @@
+ALTER TABLE transactions ADD COLUMN signature TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE ceremony_sessions ADD COLUMN transaction_id INTEGER REFERENCES transactions(id);
+
+CREATE INDEX ceremony_sessions_transaction_id_idx ON ceremony_sessions (transaction_id);
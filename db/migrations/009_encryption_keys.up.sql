@@ -0,0 +1,5 @@
+CREATE TABLE encryption_keys (
+    address VARCHAR(255) PRIMARY KEY,
+    public_key VARCHAR(64) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
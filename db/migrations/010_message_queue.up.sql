@@ -0,0 +1,9 @@
+CREATE TABLE queued_messages (
+    id BIGSERIAL PRIMARY KEY,
+    address VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX queued_messages_address_idx ON queued_messages (address, id);
+CREATE INDEX queued_messages_created_at_idx ON queued_messages (created_at);
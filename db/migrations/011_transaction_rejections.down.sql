@@ -0,0 +1,3 @@
+DELETE FROM transaction_approvals WHERE decision = 'reject';
+
+ALTER TABLE transaction_approvals DROP COLUMN IF EXISTS decision;
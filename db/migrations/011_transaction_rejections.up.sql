@@ -0,0 +1,2 @@
+ALTER TABLE transaction_approvals ADD COLUMN decision VARCHAR(16) NOT NULL DEFAULT 'approve'
+    CHECK (decision IN ('approve', 'reject'));
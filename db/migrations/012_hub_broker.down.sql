@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS hub_presence;
+DROP TABLE IF EXISTS hub_events;
@@ -0,0 +1,17 @@
+CREATE TABLE hub_events (
+    id BIGSERIAL PRIMARY KEY,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX hub_events_created_at_idx ON hub_events (created_at);
+
+CREATE TABLE hub_presence (
+    instance_id VARCHAR(64) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    connections INTEGER NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (instance_id, address)
+);
+
+CREATE INDEX hub_presence_address_idx ON hub_presence (address);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS organization_events;
+
+ALTER TABLE organizations DROP COLUMN IF EXISTS event_seq;
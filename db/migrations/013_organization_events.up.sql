@@ -0,0 +1,11 @@
+ALTER TABLE organizations ADD COLUMN event_seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE organization_events (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    seq BIGINT NOT NULL,
+    frame JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, seq)
+);
+
+CREATE INDEX organization_events_created_at_idx ON organization_events (created_at);
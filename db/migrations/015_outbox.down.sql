@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS webhook_deliveries_outbox_id_idx;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS outbox_id;
+
+DROP INDEX IF EXISTS queued_messages_outbox_id_idx;
+ALTER TABLE queued_messages DROP COLUMN IF EXISTS outbox_id;
+
+ALTER TABLE organization_events DROP COLUMN IF EXISTS outbox_id;
+
+DROP TABLE IF EXISTS outbox;
@@ -0,0 +1,26 @@
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    event VARCHAR(64) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'dispatched', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (organization_id, id) WHERE status = 'pending';
+CREATE INDEX outbox_created_at_idx ON outbox (created_at) WHERE status <> 'pending';
+
+-- Consumers remember which outbox event produced a row, so a retried event is not stored twice.
+ALTER TABLE organization_events ADD COLUMN outbox_id BIGINT UNIQUE;
+
+ALTER TABLE queued_messages ADD COLUMN outbox_id BIGINT;
+CREATE UNIQUE INDEX queued_messages_outbox_id_idx ON queued_messages (address, outbox_id);
+
+ALTER TABLE webhook_deliveries ADD COLUMN outbox_id BIGINT;
+CREATE UNIQUE INDEX webhook_deliveries_outbox_id_idx ON webhook_deliveries (webhook_id, outbox_id);
@@ -0,0 +1,20 @@
+CREATE TABLE invitations (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    address VARCHAR(255) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'expired')),
+    invited_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    responded_at TIMESTAMPTZ,
+    UNIQUE (organization_id, address)
+);
+
+CREATE INDEX invitations_address_idx ON invitations (address, id);
+CREATE INDEX invitations_expires_at_idx ON invitations (expires_at) WHERE status = 'pending';
+
+-- Existing participants joined before invitations had to be accepted.
+INSERT INTO invitations (organization_id, address, status, invited_by, expires_at, responded_at)
+SELECT DISTINCT ON (p.organization_id, p.address) p.organization_id, p.address, 'accepted', COALESCE(o.admin, ''), NOW(), NOW()
+FROM participants p
+JOIN organizations o ON o.id = p.organization_id;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS transactions_membership_pending_idx;
+
+-- Applied proposals have no equivalent before this migration; approved is the closest.
+UPDATE transactions SET status = 'approved' WHERE status = 'applied';
+
+ALTER TABLE transactions DROP CONSTRAINT transactions_status_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_status_check
+    CHECK (status IN ('pending', 'approved', 'signing', 'signed', 'broadcast', 'rejected', 'expired', 'cancelled'));
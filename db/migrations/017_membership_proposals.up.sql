@@ -0,0 +1,7 @@
+ALTER TABLE transactions DROP CONSTRAINT transactions_status_check;
+ALTER TABLE transactions ADD CONSTRAINT transactions_status_check
+    CHECK (status IN ('pending', 'approved', 'signing', 'signed', 'broadcast', 'rejected', 'expired', 'cancelled', 'applied'));
+
+-- Membership proposals are checked against the current members, so only one can be pending per organization.
+CREATE UNIQUE INDEX transactions_membership_pending_idx ON transactions (organization_id)
+    WHERE status = 'pending' AND payload->>'type' = 'membership';
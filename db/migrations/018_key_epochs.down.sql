@@ -0,0 +1,11 @@
+ALTER TABLE ceremony_sessions DROP COLUMN new_participants;
+ALTER TABLE ceremony_sessions DROP COLUMN old_participants;
+
+ALTER TABLE transactions DROP COLUMN key_epoch;
+
+ALTER TABLE organizations DROP COLUMN key_refreshed_at;
+ALTER TABLE organizations DROP COLUMN reshare_session;
+ALTER TABLE organizations DROP COLUMN reshare_required;
+ALTER TABLE organizations DROP COLUMN share_threshold;
+ALTER TABLE organizations DROP COLUMN share_holders;
+ALTER TABLE organizations DROP COLUMN key_epoch;
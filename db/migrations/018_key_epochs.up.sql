@@ -0,0 +1,21 @@
+ALTER TABLE organizations ADD COLUMN key_epoch INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE organizations ADD COLUMN share_holders TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE organizations ADD COLUMN share_threshold INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE organizations ADD COLUMN reshare_required BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE organizations ADD COLUMN reshare_session VARCHAR(64);
+ALTER TABLE organizations ADD COLUMN key_refreshed_at TIMESTAMPTZ;
+
+-- Keys generated so far are taken to be held by the current participants.
+UPDATE organizations o
+SET key_epoch = 1,
+    share_threshold = o.threshold,
+    key_refreshed_at = NOW(),
+    share_holders = ARRAY(SELECT p.address FROM participants p WHERE p.organization_id = o.id ORDER BY p.address)
+WHERE o.group_public_key IS NOT NULL;
+
+ALTER TABLE transactions ADD COLUMN key_epoch INTEGER NOT NULL DEFAULT 0;
+
+UPDATE transactions t SET key_epoch = o.key_epoch FROM organizations o WHERE o.id = t.organization_id;
+
+ALTER TABLE ceremony_sessions ADD COLUMN old_participants TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE ceremony_sessions ADD COLUMN new_participants TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS organization_key_accounts;
+DROP TABLE IF EXISTS organization_keys;
@@ -0,0 +1,26 @@
+CREATE TABLE organization_keys (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    public_key TEXT NOT NULL,
+    curve VARCHAR(32) NOT NULL,
+    protocol VARCHAR(32) NOT NULL,
+    epoch INTEGER NOT NULL,
+    ceremony_id VARCHAR(64) REFERENCES ceremony_sessions(id),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (organization_id, public_key)
+);
+
+CREATE TABLE organization_key_accounts (
+    id SERIAL PRIMARY KEY,
+    key_id INTEGER NOT NULL REFERENCES organization_keys(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (key_id, path)
+);
+
+INSERT INTO organization_keys (organization_id, public_key, curve, protocol, epoch, created_at)
+SELECT id, group_public_key, 'secp256k1', 'ecdsa', key_epoch, COALESCE(key_refreshed_at, NOW())
+FROM organizations
+WHERE group_public_key IS NOT NULL;
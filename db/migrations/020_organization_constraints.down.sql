@@ -0,0 +1,5 @@
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS organizations_name_check;
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS organizations_threshold_check;
+
+ALTER TABLE participants DROP CONSTRAINT IF EXISTS participants_address_check;
+DROP INDEX IF EXISTS participants_organization_address_idx;
@@ -0,0 +1,32 @@
+-- Addresses were stored as given. Participants whose addresses differ only in
+-- case cannot be told apart once they are unique regardless of case, and which
+-- of them to keep is not ours to decide, so the migration stops and lists them.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('organization %s: %s', organization_id, addresses), E'\n')
+    INTO duplicates
+    FROM (
+        SELECT organization_id, string_agg(format('%L (participant %s)', address, id), ', ' ORDER BY id) AS addresses
+        FROM participants
+        GROUP BY organization_id, lower(address)
+        HAVING COUNT(*) > 1
+    ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'participants differ only in the case of their address, keep one of each before migrating:%', E'\n' || duplicates;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX participants_organization_address_idx ON participants (organization_id, lower(address));
+
+-- The checks hold for rows written from now on. Existing rows that break them
+-- are left as they are until an operator fixes them and validates the checks.
+ALTER TABLE participants ADD CONSTRAINT participants_address_check
+    CHECK (address <> '' AND address = btrim(address)) NOT VALID;
+
+-- Whether the threshold is reachable depends on who accepts, so the upper
+-- bound is left to the application.
+ALTER TABLE organizations ADD CONSTRAINT organizations_threshold_check CHECK (threshold >= 1) NOT VALID;
+
+ALTER TABLE organizations ADD CONSTRAINT organizations_name_check CHECK (btrim(name) <> '') NOT VALID;
@@ -0,0 +1,10 @@
+CREATE TABLE hub_subscriptions (
+    instance_id VARCHAR(64) NOT NULL,
+    room VARCHAR(64) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    connections INTEGER NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (instance_id, room, address)
+);
+
+CREATE INDEX hub_subscriptions_room_idx ON hub_subscriptions (room, address);
@@ -0,0 +1,6 @@
+DROP INDEX transactions_active_payload_hash_idx;
+CREATE UNIQUE INDEX transactions_active_payload_hash_idx ON transactions (organization_id, payload_hash)
+    WHERE status IN ('pending', 'approved', 'signing');
+
+ALTER TABLE transactions DROP COLUMN derivation_path;
+ALTER TABLE transactions DROP COLUMN account_id;
@@ -0,0 +1,8 @@
+-- Transactions of a derived account are signed with the child key at its path.
+ALTER TABLE transactions ADD COLUMN account_id INTEGER REFERENCES organization_key_accounts(id);
+ALTER TABLE transactions ADD COLUMN derivation_path TEXT NOT NULL DEFAULT '';
+
+-- The same payload may be in flight once per signing key.
+DROP INDEX transactions_active_payload_hash_idx;
+CREATE UNIQUE INDEX transactions_active_payload_hash_idx ON transactions (organization_id, COALESCE(account_id, 0), payload_hash)
+    WHERE status IN ('pending', 'approved', 'signing');
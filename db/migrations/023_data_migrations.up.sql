@@ -0,0 +1,6 @@
+-- Data migrations the server runs after the schema migrations, for changes
+-- that cannot be expressed in SQL. Each one is recorded once it has run.
+CREATE TABLE data_migrations (
+    name VARCHAR(64) PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
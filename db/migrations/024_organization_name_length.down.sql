@@ -0,0 +1,2 @@
+ALTER TABLE organizations DROP CONSTRAINT IF EXISTS organizations_name_check;
+ALTER TABLE organizations ADD CONSTRAINT organizations_name_check CHECK (btrim(name) <> '') NOT VALID;
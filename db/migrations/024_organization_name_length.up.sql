@@ -0,0 +1,8 @@
+-- Names are trimmed and 3 to 64 characters long, like the application requires.
+-- Which characters a name may contain is only checked by the application: its
+-- Unicode letter and digit classes do not depend on the database locale. As
+-- with the other organization checks, existing names are not checked until an
+-- operator fixes them and validates the constraint.
+ALTER TABLE organizations DROP CONSTRAINT organizations_name_check;
+ALTER TABLE organizations ADD CONSTRAINT organizations_name_check
+    CHECK (name = btrim(name) AND char_length(name) BETWEEN 3 AND 64) NOT VALID;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS ceremony_sessions_running_keygen_idx;
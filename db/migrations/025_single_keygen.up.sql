@@ -0,0 +1,24 @@
+-- An organization runs at most one key generation at a time, whichever
+-- instance started it. Sessions left running by an instance that went away
+-- are aborted by the server once they stop moving, so duplicates are reported
+-- rather than resolved here.
+DO $$
+DECLARE
+    duplicates TEXT;
+BEGIN
+    SELECT string_agg(format('organization %s: %s', organization_id, sessions), E'\n')
+    INTO duplicates
+    FROM (
+        SELECT organization_id, string_agg(id, ', ' ORDER BY created_at) AS sessions
+        FROM ceremony_sessions
+        WHERE kind = 'keygen' AND status = 'running'
+        GROUP BY organization_id
+        HAVING COUNT(*) > 1
+    ) d;
+    IF duplicates IS NOT NULL THEN
+        RAISE EXCEPTION 'organizations have several running key generations, abort all but one of each before migrating:%', E'\n' || duplicates;
+    END IF;
+END $$;
+
+CREATE UNIQUE INDEX ceremony_sessions_running_keygen_idx ON ceremony_sessions (organization_id)
+    WHERE kind = 'keygen' AND status = 'running';
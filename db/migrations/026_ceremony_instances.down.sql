@@ -0,0 +1 @@
+ALTER TABLE ceremony_sessions DROP COLUMN IF EXISTS instance_id;
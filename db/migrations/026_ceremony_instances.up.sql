@@ -0,0 +1,3 @@
+-- The instance running a session, which frames its participants send to other
+-- instances are forwarded to. Sessions stored before are not forwarded to.
+ALTER TABLE ceremony_sessions ADD COLUMN instance_id VARCHAR(64) NOT NULL DEFAULT '';
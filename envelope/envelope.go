@@ -0,0 +1,101 @@
+// Package envelope seals and opens end-to-end encrypted messages exchanged
+// between participants through the hub. The hub only sees the routing header;
+// the ciphertext can only be opened by the recipient.
+package envelope
+
+import (
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mpc-backend/types"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const keyInfo = "mpc-backend envelope v1"
+
+var ErrOpen = errors.New("envelope could not be opened")
+
+// GenerateKey creates a new X25519 key pair for receiving envelopes.
+func GenerateKey() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// ParsePublicKey parses a raw 32 byte X25519 public key.
+func ParsePublicKey(b []byte) (*ecdh.PublicKey, error) {
+	return ecdh.X25519().NewPublicKey(b)
+}
+
+// Seal encrypts plaintext from sender to recipient. The routing fields of
+// header (session, sender and recipient addresses, round and sequence) are
+// authenticated but left readable for the hub.
+func Seal(sender *ecdh.PrivateKey, recipient *ecdh.PublicKey, header types.Envelope, plaintext []byte) (types.Envelope, error) {
+	aead, err := newAEAD(sender, recipient, sender.PublicKey(), recipient)
+	if err != nil {
+		return header, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return header, err
+	}
+
+	header.Nonce = nonce
+	header.Ciphertext = aead.Seal(nil, nonce, plaintext, associatedData(header))
+	return header, nil
+}
+
+// Open decrypts an envelope addressed to recipient and sent by sender. Any
+// change to the routing header makes it fail.
+func Open(recipient *ecdh.PrivateKey, sender *ecdh.PublicKey, env types.Envelope) ([]byte, error) {
+	aead, err := newAEAD(recipient, sender, sender, recipient.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: bad nonce size", ErrOpen)
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, associatedData(env))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOpen, err)
+	}
+	return plaintext, nil
+}
+
+// newAEAD derives the XChaCha20-Poly1305 key shared by a sender and recipient
+// pair. Both public keys are bound into the key so it is direction specific.
+func newAEAD(own *ecdh.PrivateKey, peer, senderPub, recipientPub *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := own.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	info := append([]byte(keyInfo), senderPub.Bytes()...)
+	info = append(info, recipientPub.Bytes()...)
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, nil, info), key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// associatedData encodes the routing header with length prefixes so that no
+// two headers share an encoding.
+func associatedData(env types.Envelope) []byte {
+	var ad []byte
+	for _, field := range []string{env.SessionID, env.From, env.To} {
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(field)))
+		ad = append(ad, field...)
+	}
+	ad = binary.BigEndian.AppendUint64(ad, uint64(env.Round))
+	ad = binary.BigEndian.AppendUint64(ad, env.Sequence)
+	return ad
+}
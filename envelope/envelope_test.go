@@ -0,0 +1,137 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/hex"
+	"errors"
+	"mpc-backend/types"
+	"testing"
+)
+
+// The X25519 key pairs of RFC 7748 section 6.1.
+const (
+	alicePrivate = "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"
+	alicePublic  = "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"
+	bobPrivate   = "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"
+	bobPublic    = "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"
+	sharedSecret = "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742"
+)
+
+func privateKey(t *testing.T, s string) *ecdh.PrivateKey {
+	t.Helper()
+	b, _ := hex.DecodeString(s)
+	key, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		t.Fatalf("bad private key: %v", err)
+	}
+	return key
+}
+
+func publicKey(t *testing.T, s string) *ecdh.PublicKey {
+	t.Helper()
+	b, _ := hex.DecodeString(s)
+	key, err := ParsePublicKey(b)
+	if err != nil {
+		t.Fatalf("bad public key: %v", err)
+	}
+	return key
+}
+
+func testHeader() types.Envelope {
+	return types.Envelope{SessionID: "session", From: "0xalice", To: "0xbob", Round: 2, Sequence: 7}
+}
+
+func TestKeys(t *testing.T) {
+	alice, bob := privateKey(t, alicePrivate), privateKey(t, bobPrivate)
+	if got := hex.EncodeToString(alice.PublicKey().Bytes()); got != alicePublic {
+		t.Errorf("alice public key = %s, want %s", got, alicePublic)
+	}
+	shared, err := alice.ECDH(publicKey(t, bobPublic))
+	if err != nil || hex.EncodeToString(shared) != sharedSecret {
+		t.Errorf("shared secret = %x (%v), want %s", shared, err, sharedSecret)
+	}
+	if _, err := ParsePublicKey(make([]byte, 31)); err == nil {
+		t.Error("ParsePublicKey accepted a 31 byte key")
+	}
+	if bob.PublicKey().Equal(alice.PublicKey()) {
+		t.Error("distinct private keys share a public key")
+	}
+}
+
+// TestSealVector pins the wire format: the key derivation, the cipher and the
+// encoding of the routing header.
+func TestSealVector(t *testing.T) {
+	alice, bob := privateKey(t, alicePrivate), privateKey(t, bobPrivate)
+	aead, err := newAEAD(alice, bob.PublicKey(), alice.PublicKey(), bob.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := testHeader()
+	header.Nonce = make([]byte, aead.NonceSize())
+	header.Ciphertext = aead.Seal(nil, header.Nonce, []byte("round 2 share"), associatedData(header))
+
+	const want = "9f4a518bed2c40de44ec5007f35210bd3c7ba8171184ac2a0fc2abb19d"
+	if got := hex.EncodeToString(header.Ciphertext); got != want {
+		t.Errorf("ciphertext = %s, want %s", got, want)
+	}
+	plaintext, err := Open(bob, alice.PublicKey(), header)
+	if err != nil || string(plaintext) != "round 2 share" {
+		t.Errorf("Open = %q (%v), want the sealed plaintext", plaintext, err)
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	alice, bob := privateKey(t, alicePrivate), privateKey(t, bobPrivate)
+	eve, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("round 2 share")
+	sealed, err := Seal(alice, bob.PublicKey(), testHeader(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Seal(alice, bob.PublicKey(), testHeader(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(sealed.Nonce, again.Nonce) || bytes.Equal(sealed.Ciphertext, again.Ciphertext) {
+		t.Error("sealing twice reused the nonce")
+	}
+
+	tests := []struct {
+		name      string
+		recipient *ecdh.PrivateKey
+		sender    *ecdh.PublicKey
+		modify    func(*types.Envelope)
+		valid     bool
+	}{
+		{"unchanged", bob, alice.PublicKey(), func(*types.Envelope) {}, true},
+		{"session changed", bob, alice.PublicKey(), func(e *types.Envelope) { e.SessionID = "other" }, false},
+		{"sender changed", bob, alice.PublicKey(), func(e *types.Envelope) { e.From = "0xeve" }, false},
+		{"recipient changed", bob, alice.PublicKey(), func(e *types.Envelope) { e.To = "0xeve" }, false},
+		{"round changed", bob, alice.PublicKey(), func(e *types.Envelope) { e.Round = 3 }, false},
+		{"sequence changed", bob, alice.PublicKey(), func(e *types.Envelope) { e.Sequence = 8 }, false},
+		{"fields shifted", bob, alice.PublicKey(), func(e *types.Envelope) { e.SessionID, e.From = "session0", "xalice" }, false},
+		{"ciphertext changed", bob, alice.PublicKey(), func(e *types.Envelope) { e.Ciphertext[0] ^= 1 }, false},
+		{"short nonce", bob, alice.PublicKey(), func(e *types.Envelope) { e.Nonce = e.Nonce[:12] }, false},
+		{"other recipient", eve, alice.PublicKey(), func(*types.Envelope) {}, false},
+		{"other sender", bob, eve.PublicKey(), func(*types.Envelope) {}, false},
+		{"opened by the sender", alice, bob.PublicKey(), func(*types.Envelope) {}, false},
+	}
+	for _, tt := range tests {
+		env := sealed
+		env.Nonce = append([]byte{}, sealed.Nonce...)
+		env.Ciphertext = append([]byte{}, sealed.Ciphertext...)
+		tt.modify(&env)
+
+		got, err := Open(tt.recipient, tt.sender, env)
+		if tt.valid && (err != nil || !bytes.Equal(got, plaintext)) {
+			t.Errorf("%s: Open = %q (%v), want %q", tt.name, got, err, plaintext)
+		}
+		if !tt.valid && !errors.Is(err, ErrOpen) {
+			t.Errorf("%s: got %v, want ErrOpen", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package payload
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"mpc-backend/auth"
+	"mpc-backend/types"
+	"sort"
+	"strings"
+)
+
+// TypeEVM is an Ethereum-style transaction signed with secp256k1.
+const TypeEVM = "evm"
+
+// TypeMembership is a proposal to change an organization's members or threshold.
+const TypeMembership = "membership"
+
+// membershipDomain separates membership proposal hashes from transaction hashes.
+const membershipDomain = "membership:"
+
+var ErrInvalidPayload = errors.New("invalid transaction payload")
+
+// Normalize validates a payload and returns it in canonical form: lowercase
+// hex, decimal amounts without leading zeros and no unused fee fields.
+func Normalize(p types.TransactionPayload) (types.TransactionPayload, error) {
+	switch p.Type {
+	case TypeEVM:
+		if p.EVM == nil {
+			return p, fmt.Errorf("%w: missing evm payload", ErrInvalidPayload)
+		}
+		evm, err := normalizeEVM(*p.EVM)
+		if err != nil {
+			return p, err
+		}
+		return types.TransactionPayload{Type: TypeEVM, EVM: &evm}, nil
+	case TypeMembership:
+		if p.Membership == nil {
+			return p, fmt.Errorf("%w: missing membership payload", ErrInvalidPayload)
+		}
+		m, err := normalizeMembership(*p.Membership)
+		if err != nil {
+			return p, err
+		}
+		return types.TransactionPayload{Type: TypeMembership, Membership: &m}, nil
+	default:
+		return p, fmt.Errorf("%w: unsupported type %q", ErrInvalidPayload, p.Type)
+	}
+}
+
+// Hash returns the canonical hash of a normalized payload. For EVM payloads this is
+// the hash the group key signs: EIP-1559 when fee caps are set, EIP-155 otherwise.
+// Participants sign it as part of their DecisionMessage.
+func Hash(p types.TransactionPayload) ([]byte, error) {
+	switch p.Type {
+	case TypeEVM:
+		if p.EVM == nil {
+			return nil, fmt.Errorf("%w: missing evm payload", ErrInvalidPayload)
+		}
+		return hashEVM(*p.EVM)
+	case TypeMembership:
+		if p.Membership == nil {
+			return nil, fmt.Errorf("%w: missing membership payload", ErrInvalidPayload)
+		}
+		encoded, err := json.Marshal(p.Membership)
+		if err != nil {
+			return nil, err
+		}
+		return auth.Keccak256([]byte(membershipDomain), encoded), nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %q", ErrInvalidPayload, p.Type)
+	}
+}
+
+// HexHash returns the 0x prefixed hex encoding of a hash.
+func HexHash(hash []byte) string {
+	return "0x" + hex.EncodeToString(hash)
+}
+
+// Decisions a participant signs over a transaction.
+const (
+	DecisionApprove = "approve"
+	DecisionReject  = "reject"
+)
+
+// DecisionMessage returns the message a participant signs to approve or reject
+// a transaction. Besides the payload hash it names the organization and the
+// transaction, so a signature cannot be replayed for an identical payload of
+// another organization or of a transaction created later.
+func DecisionMessage(decision string, orgID, txnID int, hash []byte) []byte {
+	return []byte(fmt.Sprintf("mpc-backend %s\norganization: %d\ntransaction: %d\npayload: %s", decision, orgID, txnID, HexHash(hash)))
+}
+
+func normalizeEVM(p types.EVMPayload) (types.EVMPayload, error) {
+	var out types.EVMPayload
+
+	chainID, err := parseAmount("chain_id", p.ChainID)
+	if err != nil {
+		return out, err
+	}
+	if chainID.Sign() == 0 {
+		return out, fmt.Errorf("%w: chain_id must be positive", ErrInvalidPayload)
+	}
+	out.ChainID = chainID.String()
+	out.Nonce = p.Nonce
+
+	if p.To != "" {
+		to, err := auth.DecodeHex(p.To)
+		if err != nil || len(to) != 20 {
+			return out, fmt.Errorf("%w: to must be a 20 byte hex address", ErrInvalidPayload)
+		}
+		out.To = "0x" + hex.EncodeToString(to)
+	}
+
+	value, err := parseAmount("value", p.Value)
+	if err != nil {
+		return out, err
+	}
+	out.Value = value.String()
+
+	data, err := auth.DecodeHex(p.Data)
+	if err != nil {
+		return out, fmt.Errorf("%w: data must be hex", ErrInvalidPayload)
+	}
+	out.Data = "0x" + hex.EncodeToString(data)
+	if out.To == "" && len(data) == 0 {
+		return out, fmt.Errorf("%w: contract creation requires data", ErrInvalidPayload)
+	}
+
+	if p.Gas == 0 {
+		return out, fmt.Errorf("%w: gas must be positive", ErrInvalidPayload)
+	}
+	out.Gas = p.Gas
+
+	switch {
+	case p.MaxFeePerGas != "":
+		if p.GasPrice != "" {
+			return out, fmt.Errorf("%w: gas_price cannot be combined with max_fee_per_gas", ErrInvalidPayload)
+		}
+		maxFee, err := parseAmount("max_fee_per_gas", p.MaxFeePerGas)
+		if err != nil {
+			return out, err
+		}
+		tip, err := parseAmount("max_priority_fee_per_gas", p.MaxPriorityFeePerGas)
+		if err != nil {
+			return out, err
+		}
+		if tip.Cmp(maxFee) > 0 {
+			return out, fmt.Errorf("%w: max_priority_fee_per_gas exceeds max_fee_per_gas", ErrInvalidPayload)
+		}
+		out.MaxFeePerGas = maxFee.String()
+		out.MaxPriorityFeePerGas = tip.String()
+	case p.GasPrice != "":
+		if p.MaxPriorityFeePerGas != "" {
+			return out, fmt.Errorf("%w: max_priority_fee_per_gas requires max_fee_per_gas", ErrInvalidPayload)
+		}
+		gasPrice, err := parseAmount("gas_price", p.GasPrice)
+		if err != nil {
+			return out, err
+		}
+		out.GasPrice = gasPrice.String()
+	default:
+		return out, fmt.Errorf("%w: either gas_price or max_fee_per_gas is required", ErrInvalidPayload)
+	}
+
+	return out, nil
+}
+
+// normalizeMembership trims, deduplicates and sorts the addresses of a
+// membership proposal so equal proposals hash the same.
+func normalizeMembership(p types.MembershipPayload) (types.MembershipPayload, error) {
+	out := types.MembershipPayload{OrganizationID: p.OrganizationID, Threshold: p.Threshold}
+	if p.OrganizationID <= 0 {
+		return out, fmt.Errorf("%w: organization_id is required", ErrInvalidPayload)
+	}
+	if p.Threshold < 0 {
+		return out, fmt.Errorf("%w: threshold cannot be negative", ErrInvalidPayload)
+	}
+
+	out.Add = normalizeAddresses(p.Add)
+	out.Remove = normalizeAddresses(p.Remove)
+	for _, a := range out.Add {
+		for _, r := range out.Remove {
+			if a == r {
+				return out, fmt.Errorf("%w: %s is both added and removed", ErrInvalidPayload, a)
+			}
+		}
+	}
+	if len(out.Add) == 0 && len(out.Remove) == 0 && out.Threshold == 0 {
+		return out, fmt.Errorf("%w: membership proposal changes nothing", ErrInvalidPayload)
+	}
+	return out, nil
+}
+
+func normalizeAddresses(addresses []string) []string {
+	seen := make(map[string]bool, len(addresses))
+	var out []string
+	for _, a := range addresses {
+		a = strings.TrimSpace(a)
+		if a != "" && !seen[a] {
+			seen[a] = true
+			out = append(out, a)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func hashEVM(p types.EVMPayload) ([]byte, error) {
+	chainID, ok := new(big.Int).SetString(p.ChainID, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: chain_id is not normalized", ErrInvalidPayload)
+	}
+	value, ok := new(big.Int).SetString(p.Value, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: value is not normalized", ErrInvalidPayload)
+	}
+	var to []byte
+	if p.To != "" {
+		to, _ = auth.DecodeHex(p.To)
+	}
+	data, err := auth.DecodeHex(p.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: data is not normalized", ErrInvalidPayload)
+	}
+
+	if p.MaxFeePerGas != "" {
+		maxFee, _ := new(big.Int).SetString(p.MaxFeePerGas, 10)
+		tip, _ := new(big.Int).SetString(p.MaxPriorityFeePerGas, 10)
+		if maxFee == nil || tip == nil {
+			return nil, fmt.Errorf("%w: fees are not normalized", ErrInvalidPayload)
+		}
+		encoded := rlpList(
+			rlpBig(chainID), rlpUint(p.Nonce), rlpBig(tip), rlpBig(maxFee), rlpUint(p.Gas),
+			rlpBytes(to), rlpBig(value), rlpBytes(data), rlpList(),
+		)
+		return auth.Keccak256([]byte{0x02}, encoded), nil
+	}
+
+	gasPrice, ok := new(big.Int).SetString(p.GasPrice, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: gas_price is not normalized", ErrInvalidPayload)
+	}
+	encoded := rlpList(
+		rlpUint(p.Nonce), rlpBig(gasPrice), rlpUint(p.Gas), rlpBytes(to), rlpBig(value),
+		rlpBytes(data), rlpBig(chainID), rlpUint(0), rlpUint(0),
+	)
+	return auth.Keccak256(encoded), nil
+}
+
+// parseAmount parses a non-negative decimal or 0x prefixed hex quantity. Empty means zero.
+func parseAmount(field, s string) (*big.Int, error) {
+	if s == "" {
+		return new(big.Int), nil
+	}
+	v, ok := new(big.Int), false
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		v, ok = v.SetString(s[2:], 16)
+	} else {
+		v, ok = v.SetString(s, 10)
+	}
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("%w: %s must be a non-negative 256 bit quantity", ErrInvalidPayload, field)
+	}
+	return v, nil
+}
@@ -0,0 +1,172 @@
+package payload
+
+import (
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"mpc-backend/auth"
+	"mpc-backend/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRLP(t *testing.T) {
+	lorem := "Lorem ipsum dolor sit amet, consectetur adipisicing elit"
+	tests := []struct {
+		name    string
+		encoded []byte
+		want    string
+	}{
+		{"empty string", rlpBytes(nil), "80"},
+		{"single byte", rlpBytes([]byte{0x0f}), "0f"},
+		{"byte above 0x7f", rlpBytes([]byte{0x80}), "8180"},
+		{"dog", rlpBytes([]byte("dog")), "83646f67"},
+		{"zero", rlpUint(0), "80"},
+		{"fifteen", rlpUint(15), "0f"},
+		{"1024", rlpUint(1024), "820400"},
+		{"big integer", rlpBig(new(big.Int).Lsh(big.NewInt(1), 64)), "89010000000000000000"},
+		{"empty list", rlpList(), "c0"},
+		{"cat and dog", rlpList(rlpBytes([]byte("cat")), rlpBytes([]byte("dog"))), "c88363617483646f67"},
+		{"nested lists", rlpList(rlpList(), rlpList(rlpList()), rlpList(rlpList(), rlpList(rlpList()))), "c7c0c1c0c3c0c1c0"},
+		{"56 byte string", rlpBytes([]byte(lorem)), "b838" + hex.EncodeToString([]byte(lorem))},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(tt.encoded); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHashEVM(t *testing.T) {
+	// Signing data of the EIP-1559 payload below, encoded by hand.
+	eip1559, _ := hex.DecodeString("02ef0180843b9aca008477359400825208943535353535353535353535353535353535353535880de0b6b3a764000080c0")
+
+	tests := []struct {
+		name    string
+		payload types.EVMPayload
+		want    string
+	}{
+		{
+			// The example transaction of EIP-155.
+			"EIP-155",
+			types.EVMPayload{ChainID: "1", Nonce: 9, GasPrice: "20000000000", Gas: 21000, To: "0x3535353535353535353535353535353535353535", Value: "1000000000000000000", Data: "0x"},
+			"daf5a779ae972f972197303d7b574746c7ef83eadac0f2791ad23db92e4c8e53",
+		},
+		{
+			"EIP-1559",
+			types.EVMPayload{ChainID: "1", MaxPriorityFeePerGas: "1000000000", MaxFeePerGas: "2000000000", Gas: 21000, To: "0x3535353535353535353535353535353535353535", Value: "1000000000000000000", Data: "0x"},
+			hex.EncodeToString(auth.Keccak256(eip1559)),
+		},
+	}
+	for _, tt := range tests {
+		hash, err := Hash(types.TransactionPayload{Type: TypeEVM, EVM: &tt.payload})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got := hex.EncodeToString(hash); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeEVM(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload types.EVMPayload
+		want    types.EVMPayload
+		err     string
+	}{
+		{
+			name:    "hex quantities and upper case",
+			payload: types.EVMPayload{ChainID: "0x01", Nonce: 3, To: "0x3535353535353535353535353535353535353535", Value: "0x0de0b6b3a7640000", Data: "0xABCD", Gas: 21000, GasPrice: "020"},
+			want:    types.EVMPayload{ChainID: "1", Nonce: 3, To: "0x3535353535353535353535353535353535353535", Value: "1000000000000000000", Data: "0xabcd", Gas: 21000, GasPrice: "20"},
+		},
+		{
+			name:    "fee caps with default tip",
+			payload: types.EVMPayload{ChainID: "5", To: "0x3535353535353535353535353535353535353535", Gas: 21000, MaxFeePerGas: "7"},
+			want:    types.EVMPayload{ChainID: "5", To: "0x3535353535353535353535353535353535353535", Value: "0", Data: "0x", Gas: 21000, MaxFeePerGas: "7", MaxPriorityFeePerGas: "0"},
+		},
+		{name: "zero chain id", payload: types.EVMPayload{ChainID: "0", Gas: 1, GasPrice: "1", Data: "0x00"}, err: "chain_id must be positive"},
+		{name: "short to", payload: types.EVMPayload{ChainID: "1", To: "0x35", Gas: 1, GasPrice: "1"}, err: "to must be a 20 byte hex address"},
+		{name: "creation without data", payload: types.EVMPayload{ChainID: "1", Gas: 1, GasPrice: "1"}, err: "contract creation requires data"},
+		{name: "no gas", payload: types.EVMPayload{ChainID: "1", Data: "0x00", GasPrice: "1"}, err: "gas must be positive"},
+		{name: "no fees", payload: types.EVMPayload{ChainID: "1", Data: "0x00", Gas: 1}, err: "either gas_price or max_fee_per_gas is required"},
+		{name: "both fee kinds", payload: types.EVMPayload{ChainID: "1", Data: "0x00", Gas: 1, GasPrice: "1", MaxFeePerGas: "1"}, err: "gas_price cannot be combined"},
+		{name: "tip above cap", payload: types.EVMPayload{ChainID: "1", Data: "0x00", Gas: 1, MaxFeePerGas: "1", MaxPriorityFeePerGas: "2"}, err: "exceeds max_fee_per_gas"},
+		{name: "tip without cap", payload: types.EVMPayload{ChainID: "1", Data: "0x00", Gas: 1, GasPrice: "1", MaxPriorityFeePerGas: "1"}, err: "requires max_fee_per_gas"},
+		{name: "negative value", payload: types.EVMPayload{ChainID: "1", Data: "0x00", Gas: 1, GasPrice: "1", Value: "-1"}, err: "value must be a non-negative 256 bit quantity"},
+		{name: "value above 256 bits", payload: types.EVMPayload{ChainID: "1", Data: "0x00", Gas: 1, GasPrice: "1", Value: "0x1" + strings.Repeat("0", 64)}, err: "value must be a non-negative 256 bit quantity"},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(types.TransactionPayload{Type: TypeEVM, EVM: &tt.payload})
+		if tt.err != "" {
+			if !errors.Is(err, ErrInvalidPayload) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got %v, want an invalid payload error containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got.EVM, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *got.EVM, tt.want)
+		}
+	}
+}
+
+func TestNormalizeMembership(t *testing.T) {
+	a := types.MembershipPayload{OrganizationID: 1, Add: []string{" 0xb", "0xa", "0xb"}, Remove: []string{"0xc"}}
+	b := types.MembershipPayload{OrganizationID: 1, Add: []string{"0xa", "0xb"}, Remove: []string{"0xc", ""}}
+	hashes := make([]string, 2)
+	for i, m := range []types.MembershipPayload{a, b} {
+		p, err := Normalize(types.TransactionPayload{Type: TypeMembership, Membership: &m})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		hash, err := Hash(p)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		hashes[i] = HexHash(hash)
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("equal proposals hash differently: %s and %s", hashes[0], hashes[1])
+	}
+
+	tests := []struct {
+		name    string
+		payload types.MembershipPayload
+	}{
+		{"no organization", types.MembershipPayload{Threshold: 1}},
+		{"negative threshold", types.MembershipPayload{OrganizationID: 1, Threshold: -1}},
+		{"added and removed", types.MembershipPayload{OrganizationID: 1, Add: []string{"0xa"}, Remove: []string{" 0xa"}}},
+		{"no change", types.MembershipPayload{OrganizationID: 1, Add: []string{" "}}},
+	}
+	for _, tt := range tests {
+		if _, err := Normalize(types.TransactionPayload{Type: TypeMembership, Membership: &tt.payload}); !errors.Is(err, ErrInvalidPayload) {
+			t.Errorf("%s: got %v, want ErrInvalidPayload", tt.name, err)
+		}
+	}
+}
+
+func TestDecisionMessage(t *testing.T) {
+	hash := make([]byte, 32)
+	hash[31] = 1
+	tests := []struct {
+		decision string
+		orgID    int
+		txnID    int
+		want     string
+	}{
+		{DecisionApprove, 3, 12, "mpc-backend approve\norganization: 3\ntransaction: 12\npayload: 0x0000000000000000000000000000000000000000000000000000000000000001"},
+		{DecisionReject, 3, 12, "mpc-backend reject\norganization: 3\ntransaction: 12\npayload: 0x0000000000000000000000000000000000000000000000000000000000000001"},
+	}
+	for _, tt := range tests {
+		if got := string(DecisionMessage(tt.decision, tt.orgID, tt.txnID, hash)); got != tt.want {
+			t.Errorf("DecisionMessage(%s, %d, %d) = %q, want %q", tt.decision, tt.orgID, tt.txnID, got, tt.want)
+		}
+	}
+}
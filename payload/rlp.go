@@ -0,0 +1,37 @@
+package payload
+
+import "math/big"
+
+// The helpers below implement the subset of Ethereum's RLP encoding needed to
+// build transaction signing hashes.
+
+func rlpBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+func rlpUint(v uint64) []byte {
+	return rlpBytes(new(big.Int).SetUint64(v).Bytes())
+}
+
+func rlpBig(v *big.Int) []byte {
+	return rlpBytes(v.Bytes())
+}
+
+func rlpList(items ...[]byte) []byte {
+	var body []byte
+	for _, item := range items {
+		body = append(body, item...)
+	}
+	return append(rlpHeader(0xc0, len(body)), body...)
+}
+
+func rlpHeader(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	sizeBytes := new(big.Int).SetInt64(int64(size)).Bytes()
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
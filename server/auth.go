@@ -0,0 +1,181 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mpc-backend/auth"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"mpc-backend/validation"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type contextKey string
+
+const addressContextKey contextKey = "address"
+
+func (h *Handler) ChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	var req types.AuthChallengeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Address == "" {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	address, err := h.addresses.Normalize(req.Address)
+	if err != nil {
+		writeValidationError(w, validation.Errors{{Field: "address", Message: err.Error()}})
+		return
+	}
+	req.Address = address
+
+	nonce, err := randomToken(16)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate nonce")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(h.authConf.ChallengeTTL).UTC()
+	message := fmt.Sprintf("Sign in to mpc-backend\n\nAddress: %s\nNonce: %s\nExpires: %s",
+		req.Address, nonce, expiresAt.Format(time.RFC3339))
+
+	if err := h.crudHandler.CreateChallenge(req.Address, nonce, message, expiresAt); err != nil {
+		log.Error().Err(err).Msg("Failed to store challenge")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.AuthChallengeResponse{
+		Nonce:     nonce,
+		Message:   message,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (h *Handler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req types.AuthLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	signature, err := auth.DecodeHex(req.Signature)
+	if err != nil {
+		http.Error(w, "Invalid signature encoding", http.StatusBadRequest)
+		return
+	}
+	// Sessions carry the canonical spelling of an address, as participants are stored with it.
+	address, err := h.addresses.Normalize(req.Address)
+	if err != nil {
+		writeValidationError(w, validation.Errors{{Field: "address", Message: err.Error()}})
+		return
+	}
+	req.Address = address
+
+	message, err := h.crudHandler.ConsumeChallenge(req.Address, req.Nonce)
+	if err != nil {
+		if errors.Is(err, crud.ErrChallengeNotFound) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to consume challenge")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.verifiers.Verify(req.Scheme, req.Address, []byte(message), signature); err != nil {
+		log.Debug().Err(err).Str("address", req.Address).Msg("Login signature rejected")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to generate session token")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+	expiresAt := time.Now().Add(h.authConf.SessionTTL).UTC()
+
+	if err := h.crudHandler.CreateSession(hashToken(token), req.Address, expiresAt); err != nil {
+		log.Error().Err(err).Msg("Failed to store session")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.AuthLoginResponse{
+		Token:     token,
+		Address:   req.Address,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// authenticated rejects requests without a live session token and puts the
+// session address into the request context. Browsers cannot set headers on
+// WebSocket upgrades, so the token is also accepted as a query parameter.
+func (h *Handler) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			token = r.URL.Query().Get("token")
+		}
+		if token == "" {
+			http.Error(w, "Missing session token", http.StatusUnauthorized)
+			return
+		}
+
+		address, err := h.crudHandler.GetSessionAddress(hashToken(token))
+		if err != nil {
+			if errors.Is(err, crud.ErrSessionNotFound) {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			log.Error().Err(err).Msg("Failed to fetch session")
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), addressContextKey, address)))
+	}
+}
+
+// sessionAddress returns the address of the authenticated caller.
+func sessionAddress(r *http.Request) string {
+	address, _ := r.Context().Value(addressContextKey).(string)
+	return address
+}
+
+// pruneAuth periodically removes expired challenges and sessions.
+func (h *Handler) pruneAuth() {
+	ticker := time.NewTicker(h.authConf.SessionTTL)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := h.crudHandler.DeleteExpiredAuth(); err != nil {
+			log.Error().Err(err).Msg("Failed to prune expired sessions")
+		}
+	}
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mpc-backend/ceremony"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"mpc-backend/wallet"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) StartKeygenHandler(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid organization id", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Organization not found")
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	address := sessionAddress(r)
+	if org.Admin != address {
+		http.Error(w, "Only the organization admin can start key generation", http.StatusForbidden)
+		return
+	}
+	if org.GroupPublicKey != "" {
+		http.Error(w, "Organization already has a group key", http.StatusConflict)
+		return
+	}
+	if !org.Ready {
+		http.Error(w, fmt.Sprintf("Only %d of the %d members needed have accepted their invitation", len(org.Participants), org.Threshold), http.StatusConflict)
+		return
+	}
+
+	participants := make([]string, 0, len(org.Participants))
+	for _, p := range org.Participants {
+		participants = append(participants, p.Address)
+	}
+
+	session, err := h.startKeygen(types.CeremonySession{
+		Kind:           ceremony.KindKeygen,
+		OrganizationID: org.ID,
+		Initiator:      address,
+		Participants:   participants,
+		Instance:       h.hub.ID(),
+	})
+	if err != nil {
+		if errors.Is(err, ceremony.ErrTooFewMembers) || errors.Is(err, crud.ErrKeygenInProgress) || errors.Is(err, crud.ErrGroupKeyExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to start key generation")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// startKeygen stores a key generation session before opening it, so that only
+// one runs per organization across instances.
+func (h *Handler) startKeygen(spec types.CeremonySession) (types.CeremonySession, error) {
+	session, err := h.ceremonies.Prepare(spec)
+	if err != nil {
+		return session, err
+	}
+	if err := h.crudHandler.BeginKeygen(session); err != nil {
+		return types.CeremonySession{}, err
+	}
+
+	started, err := h.ceremonies.Start(session, ceremony.Callbacks{
+		OnRound:    h.saveCeremony,
+		OnComplete: h.completeKeygen,
+		OnAbort:    h.saveCeremony,
+	})
+	if err != nil {
+		session.Status = types.CeremonyAborted
+		session.Reason = err.Error()
+		session.UpdatedAt = time.Now().UTC()
+		h.saveCeremony(session)
+		return types.CeremonySession{}, err
+	}
+	return started, nil
+}
+
+func (h *Handler) GetCeremonyHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	session, running := h.ceremonies.Get(id)
+	if !running {
+		var err error
+		session, err = h.crudHandler.GetCeremony(id)
+		if err != nil {
+			if errors.Is(err, crud.ErrCeremonyNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			log.Error().Err(err).Msg("Failed to fetch ceremony")
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !h.requireParticipant(w, session.OrganizationID, sessionAddress(r)) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// saveCeremony persists the current state of a session.
+func (h *Handler) saveCeremony(s types.CeremonySession) {
+	if err := h.crudHandler.SaveCeremony(s); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to save ceremony session")
+	}
+}
+
+// completeKeygen stores the group public key agreed on by every participant in
+// its compressed form.
+func (h *Handler) completeKeygen(s types.CeremonySession) {
+	pub, err := wallet.ParsePublicKey(s.Result)
+	if err != nil {
+		s.Status = types.CeremonyAborted
+		s.Reason = err.Error()
+		s.Blamed = s.Participants
+		s.Result = ""
+		h.saveCeremony(s)
+		log.Warn().Str("session", s.ID).Err(err).Msg("Key generation produced an invalid group key")
+		return
+	}
+	s.Result = wallet.EncodePublicKey(pub)
+
+	if err := h.crudHandler.CompleteKeygen(s); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to store group public key")
+		return
+	}
+	log.Info().Int("organization", s.OrganizationID).Str("session", s.ID).Msg("Key generation completed")
+}
+
+// releaseStaleCeremonies aborts the stored sessions that have not moved for
+// staleAfter and unblocks what they held up.
+func (h *Handler) releaseStaleCeremonies(staleAfter time.Duration) {
+	released, err := h.crudHandler.ReleaseStaleReshares(staleAfter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to release stale key reshares")
+	} else if released > 0 {
+		log.Warn().Int64("count", released).Msg("Released stale key reshares")
+	}
+
+	released, err = h.crudHandler.ReleaseStaleKeygens(staleAfter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to release stale key generations")
+	} else if released > 0 {
+		log.Warn().Int64("count", released).Msg("Released stale key generations")
+	}
+
+	h.releaseStaleSignings(staleAfter)
+}
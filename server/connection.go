@@ -0,0 +1,237 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// PolicyDropOldest takes the oldest queued message off a connection's full
+	// send queue, leaving it to the offline queue of the address.
+	PolicyDropOldest = "drop-oldest"
+	// PolicyDisconnect closes a connection whose send queue is full; the
+	// queued messages go to the offline queue of the address.
+	PolicyDisconnect = "disconnect"
+)
+
+var errConnectionClosed = errors.New("connection closed")
+
+// transport writes frames to a client. Only the write pump of a connection
+// calls it, so implementations need not be safe for concurrent use.
+type transport interface {
+	write(frame []byte, timeout time.Duration) error
+	ping(timeout time.Duration) error
+	close() error
+}
+
+// Connection represents a single connection of a user, over a WebSocket or a
+// Server-Sent Events stream. An address can hold several connections at once,
+// e.g. one per device. Writes go through a bounded queue drained by the
+// connection's own write pump, since neither transport allows concurrent writers.
+type Connection struct {
+	ID      string
+	Address string
+	// Conn is the underlying socket of WebSocket connections and nil otherwise.
+	Conn *websocket.Conn
+
+	transport transport
+	send      chan []byte
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// evicted tells the write pump to give up on a slow consumer.
+	evicted   chan struct{}
+	evictOnce sync.Once
+	// undelivered receives the messages the write pump could not write when it stops.
+	undelivered func(msg []byte)
+
+	// instance is set on stand-ins for connections held by another instance,
+	// which frames forwarded from them are handled with.
+	instance string
+
+	// held buffers live room events while missed events of the room are replayed.
+	heldMu sync.Mutex
+	held   map[string][]heldEvent
+}
+
+type heldEvent struct {
+	seq   int64
+	frame []byte
+}
+
+func newConnection(id, address string, t transport, queueSize int) *Connection {
+	return &Connection{
+		ID:        id,
+		Address:   address,
+		transport: t,
+		send:      make(chan []byte, queueSize),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		evicted:   make(chan struct{}),
+	}
+}
+
+// Close stops the write pump and closes the underlying transport. It is safe to call more than once.
+func (c *Connection) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.transport.close()
+	})
+}
+
+// evict makes the write pump close the connection, which unregisters it like
+// any other closed connection. It reports whether the connection was evicted
+// by this call.
+func (c *Connection) evict() bool {
+	first := false
+	c.evictOnce.Do(func() {
+		close(c.evicted)
+		first = true
+	})
+	return first
+}
+
+// Done is closed once the connection is closed.
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+// keepAlive limits inbound frames and arms the read deadline, which every pong
+// pushes back. A connection that stops answering pings fails its next read.
+func (c *Connection) keepAlive(pongTimeout time.Duration, maxMessageSize int64) {
+	c.Conn.SetReadLimit(maxMessageSize)
+	_ = c.Conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+}
+
+// writePump writes queued messages and periodic pings to the transport until
+// the connection is closed or evicted. Messages it could not write are handed
+// to undelivered.
+func (c *Connection) writePump(writeTimeout, pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	var unwritten []byte
+	defer func() {
+		ticker.Stop()
+		c.Close()
+		c.drain(unwritten)
+		close(c.stopped)
+	}()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.evicted:
+			log.Printf("Closing slow connection %s of %s", c.ID, c.Address)
+			return
+		case <-ticker.C:
+			if err := c.transport.ping(writeTimeout); err != nil {
+				log.Printf("Error pinging %s on connection %s: %v", c.Address, c.ID, err)
+				return
+			}
+		case msg := <-c.send:
+			if err := c.transport.write(msg, writeTimeout); err != nil {
+				log.Printf("Error writing to %s on connection %s: %v", c.Address, c.ID, err)
+				unwritten = msg
+				return
+			}
+		}
+	}
+}
+
+// drain hands the message that failed to write and the ones still queued to
+// undelivered, in the order they were queued.
+func (c *Connection) drain(unwritten []byte) {
+	if c.undelivered == nil {
+		return
+	}
+	if unwritten != nil {
+		c.undelivered(unwritten)
+	}
+	for {
+		select {
+		case msg := <-c.send:
+			c.undelivered(msg)
+		default:
+			return
+		}
+	}
+}
+
+// hold starts buffering live events of a room instead of sending them.
+func (c *Connection) hold(room string) {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	if c.held == nil {
+		c.held = make(map[string][]heldEvent)
+	}
+	c.held[room] = []heldEvent{}
+}
+
+// buffer keeps a live event while its room is held and reports whether it did.
+func (c *Connection) buffer(room string, seq int64, frame []byte) bool {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	events, ok := c.held[room]
+	if !ok {
+		return false
+	}
+	c.held[room] = append(events, heldEvent{seq: seq, frame: frame})
+	return true
+}
+
+// release stops buffering a room and returns the buffered events that come
+// after the last replayed sequence number.
+func (c *Connection) release(room string, after int64) [][]byte {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	var frames [][]byte
+	for _, e := range c.held[room] {
+		if e.seq == 0 || e.seq > after {
+			frames = append(frames, e.frame)
+		}
+	}
+	delete(c.held, room)
+	return frames
+}
+
+// wait queues a message, blocking until there is room or the timeout passes.
+func (c *Connection) wait(msg []byte, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case c.send <- msg:
+		return nil
+	case <-c.done:
+		return errConnectionClosed
+	case <-timer.C:
+		return errors.New("send queue stayed full")
+	}
+}
+
+// wsTransport writes frames as WebSocket text messages.
+type wsTransport struct {
+	conn *websocket.Conn
+}
+
+func (t wsTransport) write(frame []byte, timeout time.Duration) error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(timeout))
+	return t.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+func (t wsTransport) ping(timeout time.Duration) error {
+	_ = t.conn.SetWriteDeadline(time.Now().Add(timeout))
+	return t.conn.WriteMessage(websocket.PingMessage, nil)
+}
+
+func (t wsTransport) close() error {
+	return t.conn.Close()
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EventsHandler streams hub events as Server-Sent Events for clients that
+// cannot keep a WebSocket open. ?organization=<id> limits the stream to one
+// organization; otherwise it covers every organization of the address. Event
+// IDs are a cursor of the last sequence number seen per organization, which
+// the client sends back as Last-Event-ID to resume after a disconnect.
+func (h *Handler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	address := sessionAddress(r)
+
+	var rooms []int
+	if v := r.URL.Query().Get("organization"); v != "" {
+		orgID, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid organization id", http.StatusBadRequest)
+			return
+		}
+		if !h.requireParticipant(w, orgID, address) {
+			return
+		}
+		rooms = []int{orgID}
+	} else {
+		orgs, err := h.crudHandler.GetOrganizationsByAddress(address)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch organizations for event stream")
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		for _, org := range orgs {
+			rooms = append(rooms, org.ID)
+		}
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	cursor, err := parseCursor(lastEventID)
+	if err != nil {
+		http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	t := &sseTransport{w: w, rc: http.NewResponseController(w), cursor: cursor}
+	if err := t.rc.Flush(); err != nil {
+		log.Error().Err(err).Msg("Event stream is not supported by the response writer")
+		return
+	}
+
+	conn, err := h.hub.RegisterStream(address, t)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to register event stream")
+		return
+	}
+	h.hub.ReplayQueued(conn)
+	for _, orgID := range rooms {
+		if seq, ok := cursor[orgID]; ok {
+			h.hub.ResumeOrganizationRoom(orgID, conn, seq)
+		} else {
+			h.hub.JoinOrganizationRoom(strconv.Itoa(orgID), conn)
+		}
+	}
+
+	select {
+	case <-r.Context().Done():
+	case <-conn.Done():
+	}
+	h.hub.UnregisterConnection(conn)
+	// The response writer must not be used once the handler returns.
+	<-conn.stopped
+}
+
+// sseTransport writes frames as Server-Sent Events named after the frame type.
+type sseTransport struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	// cursor is the last sequence number sent per organization.
+	cursor map[int]int64
+}
+
+func (t *sseTransport) write(frame []byte, timeout time.Duration) error {
+	var head struct {
+		Type           string `json:"type"`
+		OrganizationID int    `json:"organization_id"`
+		Seq            int64  `json:"seq"`
+	}
+	if err := json.Unmarshal(frame, &head); err != nil {
+		return err
+	}
+
+	_ = t.rc.SetWriteDeadline(time.Now().Add(timeout))
+	// Only organization events move the cursor; other events keep the last ID.
+	if head.Seq > 0 && head.OrganizationID > 0 {
+		t.cursor[head.OrganizationID] = head.Seq
+		if _, err := fmt.Fprintf(t.w, "id: %s\n", formatCursor(t.cursor)); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(t.w, "event: %s\ndata: %s\n\n", head.Type, frame); err != nil {
+		return err
+	}
+	return t.rc.Flush()
+}
+
+func (t *sseTransport) ping(timeout time.Duration) error {
+	_ = t.rc.SetWriteDeadline(time.Now().Add(timeout))
+	if _, err := fmt.Fprint(t.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	return t.rc.Flush()
+}
+
+// close is a no-op; the stream ends when EventsHandler returns.
+func (t *sseTransport) close() error {
+	return nil
+}
+
+// parseCursor reads an event ID of the form "<organization>:<seq>,...".
+func parseCursor(id string) (map[int]int64, error) {
+	cursor := make(map[int]int64)
+	if id == "" {
+		return cursor, nil
+	}
+	for _, part := range strings.Split(id, ",") {
+		org, seq, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed cursor entry %q", part)
+		}
+		orgID, err := strconv.Atoi(org)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("malformed sequence in %q", part)
+		}
+		cursor[orgID] = n
+	}
+	return cursor, nil
+}
+
+func formatCursor(cursor map[int]int64) string {
+	orgs := make([]int, 0, len(cursor))
+	for org := range cursor {
+		orgs = append(orgs, org)
+	}
+	sort.Ints(orgs)
+
+	parts := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		parts = append(parts, fmt.Sprintf("%d:%d", org, cursor[org]))
+	}
+	return strings.Join(parts, ",")
+}
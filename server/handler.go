@@ -1,13 +1,24 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mpc-backend/auth"
+	"mpc-backend/broker"
+	"mpc-backend/ceremony"
 	"mpc-backend/config"
 	crud "mpc-backend/core"
+	"mpc-backend/payload"
 	"mpc-backend/types"
+	"mpc-backend/validation"
+	"mpc-backend/webhooks"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
@@ -24,30 +35,100 @@ type Handler struct {
 
 	crudHandler *crud.CRUD
 	hub         *Hub
+
+	verifiers  *auth.Registry
+	addresses  *validation.Addresses
+	ceremonies *ceremony.Manager
+	webhooks   *webhooks.Dispatcher
+	inbound    map[string]inboundHandler
+	outboxWake chan struct{}
+
+	signingMu      sync.Mutex
+	signingRetries map[int]*signingRetry
+
+	txConf       config.TxConf
+	authConf     config.AuthConf
+	ceremonyConf config.CeremonyConf
+	queueConf    config.QueueConf
+	eventConf    config.EventConf
+	outboxConf   config.OutboxConf
+	inviteConf   config.InviteConf
+	keyConf      config.KeyConf
 }
 
-func NewHandler(conf config.Configuration, crudHandler *crud.CRUD) *Handler {
+func NewHandler(conf config.Configuration, crudHandler *crud.CRUD, b broker.Broker) *Handler {
 	handler := &Handler{}
 	handler.host = conf.ServerConf.Host
 	handler.port = conf.ServerConf.Port
+	handler.txConf = conf.TxConf
+	handler.authConf = conf.AuthConf
+	handler.ceremonyConf = conf.CeremonyConf
+	handler.queueConf = conf.QueueConf
+	handler.eventConf = conf.EventConf
+	handler.outboxConf = conf.OutboxConf
+	handler.inviteConf = conf.InviteConf
+	handler.keyConf = conf.KeyConf
+	handler.outboxWake = make(chan struct{}, 1)
+	handler.signingRetries = make(map[int]*signingRetry)
 
 	handler.crudHandler = crudHandler
 
 	handler.router = mux.NewRouter()
-	handler.hub = NewHub()
+	handler.hub = NewHub(crudHandler, b, conf.HubConf)
+	handler.verifiers = auth.NewRegistry()
+	handler.addresses = validation.NewAddresses()
+	handler.ceremonies = ceremony.NewManager(handler.hub, conf.CeremonyConf.RoundTimeout)
+	handler.inbound = handler.inboundHandlers()
+	handler.webhooks = webhooks.NewDispatcher(crudHandler, conf.WebhookConf)
+	handler.hub.SetForwardHandler(handler.handleInbound)
 
 	handler.router.HandleFunc("/health", HealthCheckHandler).Methods("GET")
+	handler.router.HandleFunc("/hub/stats", handler.authenticated(handler.HubStatsHandler)).Methods("GET")
+
+	handler.router.HandleFunc("/auth/challenge", handler.ChallengeHandler).Methods("POST")
+	handler.router.HandleFunc("/auth/login", handler.LoginHandler).Methods("POST")
 
 	//god forgive me for this atrocity
-	handler.router.HandleFunc("/organizations", handler.CreateOrganizationHandler).Methods("POST")
+	handler.router.HandleFunc("/organizations", handler.authenticated(handler.CreateOrganizationHandler)).Methods("POST")
 	handler.router.HandleFunc("/organizations/{address}", handler.GetOrganizationsByAddressHandler).Methods("GET")
 	handler.router.HandleFunc("/organization", handler.GetOrganizationByNameHandler).Methods("GET")
-
-	handler.router.HandleFunc("/ws/{address}", handler.WebSocketHandler).Methods("GET")
-	handler.router.HandleFunc("/ws/organization/{name}/{address}", handler.OrganizationWebSocketHandler).Methods("GET")
-
-	handler.router.HandleFunc("/transaction/initiate", handler.InitiateTransactionHandler).Methods("POST")
-	handler.router.HandleFunc("/transaction/confirm", handler.ConfirmTransactionHandler).Methods("POST")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/keygen", handler.authenticated(handler.StartKeygenHandler)).Methods("POST")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/reshare", handler.authenticated(handler.StartReshareHandler)).Methods("POST")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/keys", handler.authenticated(handler.GetOrganizationKeysHandler)).Methods("GET")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/keys/{key:[0-9]+}/accounts", handler.authenticated(handler.CreateKeyAccountHandler)).Methods("POST")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/invitations", handler.authenticated(handler.GetOrganizationInvitationsHandler)).Methods("GET")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/proposals", handler.authenticated(handler.ProposeMembershipHandler)).Methods("POST")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/presence", handler.authenticated(handler.GetPresenceHandler)).Methods("GET")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/webhooks", handler.authenticated(handler.CreateWebhookHandler)).Methods("POST")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/webhooks", handler.authenticated(handler.GetWebhooksHandler)).Methods("GET")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/webhooks/{webhook:[0-9]+}", handler.authenticated(handler.UpdateWebhookHandler)).Methods("PUT")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/webhooks/{webhook:[0-9]+}", handler.authenticated(handler.DeleteWebhookHandler)).Methods("DELETE")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/webhooks/{webhook:[0-9]+}/deliveries", handler.authenticated(handler.GetWebhookDeliveriesHandler)).Methods("GET")
+	handler.router.HandleFunc("/organizations/{id:[0-9]+}/webhooks/{webhook:[0-9]+}/deliveries/{delivery:[0-9]+}/redeliver", handler.authenticated(handler.RedeliverWebhookHandler)).Methods("POST")
+	handler.router.HandleFunc("/ceremonies/{id}", handler.authenticated(handler.GetCeremonyHandler)).Methods("GET")
+
+	handler.router.HandleFunc("/invitations", handler.authenticated(handler.GetInvitationsHandler)).Methods("GET")
+	handler.router.HandleFunc("/invitations/{id:[0-9]+}/accept", handler.authenticated(handler.AcceptInvitationHandler)).Methods("POST")
+	handler.router.HandleFunc("/invitations/{id:[0-9]+}/decline", handler.authenticated(handler.DeclineInvitationHandler)).Methods("POST")
+
+	handler.router.HandleFunc("/messages", handler.authenticated(handler.GetQueuedMessagesHandler)).Methods("GET")
+	handler.router.HandleFunc("/messages/ack", handler.authenticated(handler.AckMessagesHandler)).Methods("POST")
+
+	handler.router.HandleFunc("/keys/encryption", handler.authenticated(handler.SetEncryptionKeyHandler)).Methods("PUT")
+	handler.router.HandleFunc("/keys/encryption/{address}", handler.GetEncryptionKeyHandler).Methods("GET")
+
+	handler.router.HandleFunc("/ws", handler.authenticated(handler.WebSocketHandler)).Methods("GET")
+	handler.router.HandleFunc("/events", handler.authenticated(handler.EventsHandler)).Methods("GET")
+	handler.router.HandleFunc("/ws/organization/{name}", handler.authenticated(handler.OrganizationWebSocketHandler)).Methods("GET")
+
+	handler.router.HandleFunc("/transaction/initiate", handler.authenticated(handler.InitiateTransactionHandler)).Methods("POST")
+	handler.router.HandleFunc("/transaction/confirm", handler.authenticated(handler.ConfirmTransactionHandler)).Methods("POST")
+	handler.router.HandleFunc("/transaction/reject", handler.authenticated(handler.RejectTransactionHandler)).Methods("POST")
+	handler.router.HandleFunc("/transaction/cancel", handler.authenticated(handler.CancelTransactionHandler)).Methods("POST")
+	handler.router.HandleFunc("/transactions", handler.authenticated(handler.GetTransactionsHandler)).Methods("GET")
+	handler.router.HandleFunc("/transactions/{id}/history", handler.authenticated(handler.GetTransactionHistoryHandler)).Methods("GET")
+	handler.router.HandleFunc("/transactions/{id}/approvals", handler.authenticated(handler.GetTransactionApprovalsHandler)).Methods("GET")
+	handler.router.HandleFunc("/transactions/{id}/sign", handler.authenticated(handler.StartSigningHandler)).Methods("POST")
 
 	configCors(handler)
 
@@ -55,9 +136,9 @@ func NewHandler(conf config.Configuration, crudHandler *crud.CRUD) *Handler {
 }
 
 func configCors(handler *Handler) {
-	// Defaults cover HEAD, GET, POST
 	opts := cors.Options{
 		AllowedHeaders:   []string{"*"},
+		AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "DELETE"},
 		AllowedOrigins:   []string{"*"},
 		AllowCredentials: true,
 		// Enable Debugging for testing, consider disabling in production
@@ -69,6 +150,16 @@ func configCors(handler *Handler) {
 }
 
 func (h *Handler) Run() error {
+	go h.expireTransactions()
+	go h.pruneAuth()
+	go h.pruneQueuedMessages()
+	go h.pruneOrganizationEvents()
+	go h.webhooks.Run(context.Background())
+	go h.dispatchOutbox()
+	go h.pruneOutbox()
+	go h.expireInvitations()
+	go h.reshareKeys()
+
 	log.Info().Str("host", h.host).Int("port", h.port).Msg("Server started")
 	return http.ListenAndServe(fmt.Sprintf("%s:%d", h.host, h.port), h.cors.Handler(h.router))
 }
@@ -79,6 +170,11 @@ func HealthCheckHandler(w http.ResponseWriter, _ *http.Request) {
 	_, _ = io.WriteString(w, `{"alive": true}`)
 }
 
+func (h *Handler) HubStatsHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.hub.Stats())
+}
+
 func (h *Handler) CreateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
 
 	var orgReq types.CreateOrganizationRequest
@@ -87,21 +183,25 @@ func (h *Handler) CreateOrganizationHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	orgID, err := h.crudHandler.CreateOrganization(orgReq.Name, orgReq.Threshold, orgReq.Participants)
+	orgReq, err := validation.CreateOrganization(orgReq, h.addresses)
 	if err != nil {
-		log.Error().Err(err).Msg("CRUD Error")
-		http.Error(w, "Server Error", http.StatusBadGateway)
+		writeValidationError(w, err)
 		return
 	}
 
-	for _, participant := range orgReq.Participants {
-		inviteMsg := types.InvitationMessage{
-			OrganizationID:   orgID,
-			OrganizationName: orgReq.Name,
-			Message:          fmt.Sprintf("You've been invited to join organization %s", orgReq.Name),
+	expiresAt := time.Now().Add(h.inviteConf.TTL)
+	if _, err := h.crudHandler.CreateOrganization(orgReq.Name, orgReq.Threshold, orgReq.Participants, sessionAddress(r), expiresAt); err != nil {
+		if errors.Is(err, crud.ErrOrganizationExists) {
+			writeValidationError(w, validation.Errors{{Field: "name", Message: err.Error()}})
+			return
 		}
-		h.hub.NotifyUser(participant.Address, inviteMsg)
+		log.Error().Err(err).Msg("CRUD Error")
+		http.Error(w, "Server Error", http.StatusBadGateway)
+		return
 	}
+	// Participants only join once they accept. Invitations are sent from the
+	// outbox, so they survive a crash right after the commit.
+	h.wakeOutbox()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -110,11 +210,15 @@ func (h *Handler) CreateOrganizationHandler(w http.ResponseWriter, r *http.Reque
 
 func (h *Handler) GetOrganizationsByAddressHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	address := vars["address"]
-	if address == "" {
+	if vars["address"] == "" {
 		http.Error(w, "Missing address parameter", http.StatusBadRequest)
 		return
 	}
+	address, err := h.addresses.Normalize(vars["address"])
+	if err != nil {
+		writeValidationError(w, validation.Errors{{Field: "address", Message: err.Error()}})
+		return
+	}
 
 	orgs, err := h.crudHandler.GetOrganizationsByAddress(address)
 	if err != nil {
@@ -131,31 +235,71 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
+// WebSocketHandler opens a connection that can subscribe to the rooms of any
+// organization the address belongs to. With ?subscribe=all it joins all of
+// them right away.
 func (h *Handler) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	address, ok := vars["address"]
-	if !ok || address == "" {
-		http.Error(w, "Address is required", http.StatusBadRequest)
+	address := sessionAddress(r)
+
+	var rooms []int
+	switch r.URL.Query().Get("subscribe") {
+	case "":
+	case "all":
+		orgs, err := h.crudHandler.GetOrganizationsByAddress(address)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch organizations for subscription")
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		for _, org := range orgs {
+			rooms = append(rooms, org.ID)
+		}
+	default:
+		http.Error(w, "subscribe must be \"all\"", http.StatusBadRequest)
 		return
 	}
 
+	h.serveWebSocket(w, r, address, rooms, nil)
+}
+
+// serveWebSocket upgrades the request, registers the connection, joins the
+// given organization rooms and delivers what was missed while offline before
+// reading frames until the connection closes. With lastSeq set, the rooms are
+// resumed after that organization event.
+func (h *Handler) serveWebSocket(w http.ResponseWriter, r *http.Request, address string, rooms []int, lastSeq *int64) {
 	ws, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Error().Err(err).Msg("WebSocket upgrade failed")
 		return
 	}
 
-	// Register the new connection with the Hub.
-	h.hub.RegisterConnection(address, ws)
+	conn, err := h.hub.RegisterConnection(address, ws)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to register connection")
+		ws.Close()
+		return
+	}
+	h.hub.ReplayQueued(conn)
+	for _, orgID := range rooms {
+		if lastSeq != nil {
+			h.hub.ResumeOrganizationRoom(orgID, conn, *lastSeq)
+		} else {
+			h.hub.JoinOrganizationRoom(strconv.Itoa(orgID), conn)
+		}
+	}
+
+	h.readLoop(conn)
+}
 
-	// Listen for close or messages (for demonstration, we simply wait until error)
+// readLoop dispatches frames from a participant until the connection closes.
+func (h *Handler) readLoop(conn *Connection) {
 	for {
-		_, _, err := ws.ReadMessage()
+		_, data, err := conn.Conn.ReadMessage()
 		if err != nil {
-			h.hub.UnregisterConnection(address)
-			ws.Close()
+			h.hub.UnregisterConnection(conn)
 			break
 		}
+		h.handleInbound(conn, data)
 	}
 }
 
@@ -174,16 +318,22 @@ func (h *Handler) GetOrganizationByNameHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	org.Keys, err = h.organizationKeys(org.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch organization keys")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(org)
 }
 
 func (h *Handler) OrganizationWebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	orgName := vars["name"]
-	address := vars["address"]
-	if orgName == "" || address == "" {
-		http.Error(w, "Missing organization name or address", http.StatusBadRequest)
+	orgName := mux.Vars(r)["name"]
+	address := sessionAddress(r)
+	if orgName == "" {
+		http.Error(w, "Missing organization name", http.StatusBadRequest)
 		return
 	}
 
@@ -195,26 +345,23 @@ func (h *Handler) OrganizationWebSocketHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Upgrade the HTTP connection to a WebSocket.
-	ws, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Error().Err(err).Msg("WebSocket upgrade failed")
+	if !h.requireParticipant(w, org.ID, address) {
 		return
 	}
 
-	// Register the new connection and join the organization room using the org’s ID.
-	h.hub.RegisterConnection(address, ws)
-	h.hub.JoinOrganizationRoom(fmt.Sprintf("%d", org.ID), address)
-
-	// Listen for messages or closure.
-	for {
-		_, _, err := ws.ReadMessage()
-		if err != nil {
-			h.hub.UnregisterConnection(address)
-			ws.Close()
-			break
+	// ?last_seq resumes the room after the last organization event the client saw.
+	var lastSeq *int64
+	if v := r.URL.Query().Get("last_seq"); v != "" {
+		seq, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seq < 0 {
+			http.Error(w, "Invalid last_seq", http.StatusBadRequest)
+			return
 		}
+		lastSeq = &seq
 	}
+
+	// The connection starts in the organization's room and can subscribe to others like any other.
+	h.serveWebSocket(w, r, address, []int{org.ID}, lastSeq)
 }
 
 func (h *Handler) InitiateTransactionHandler(w http.ResponseWriter, r *http.Request) {
@@ -232,23 +379,58 @@ func (h *Handler) InitiateTransactionHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Construct the transaction notification.
-	notification := types.TransactionNotification{
-		OrganizationID: org.ID,
-		Initiator:      txReq.Initiator,
-		Message:        fmt.Sprintf("Transaction initiated by: %s", txReq.Initiator),
+	initiator := sessionAddress(r)
+	if !h.requireParticipant(w, org.ID, initiator) {
+		return
 	}
 
-	orgIDKey := fmt.Sprintf("%d", org.ID)
+	if txReq.Payload.Type == payload.TypeMembership {
+		http.Error(w, "Membership changes are proposed through /organizations/{id}/proposals", http.StatusBadRequest)
+		return
+	}
+	txPayload, err := payload.Normalize(txReq.Payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := payload.Hash(txPayload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Broadcast the transaction notification to everyone in the organization's room.
-	h.hub.BroadcastOrganization(orgIDKey, notification)
+	txn := types.Transaction{
+		OrganizationID: org.ID,
+		Initiator:      initiator,
+		Details:        txReq.Details,
+		Payload:        txPayload,
+		PayloadHash:    payload.HexHash(hash),
+		Threshold:      org.Threshold,
+		ExpiresAt:      time.Now().Add(h.txConf.TTL),
+	}
+	if txReq.AccountID != 0 {
+		account, err := h.crudHandler.GetKeyAccount(org.ID, txReq.AccountID)
+		if err != nil {
+			writeKeyError(w, err)
+			return
+		}
+		txn.AccountID = &account.ID
+		txn.DerivationPath = account.Path
+	}
 
-	// Register the transaction in the hub to track confirmations.
-	h.hub.RegisterPendingTransaction(orgIDKey, notification, org.Threshold)
+	// Persist the transaction so confirmations survive restarts.
+	txn, err = h.crudHandler.CreateTransaction(txn)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	// The organization is notified from the outbox.
+	h.wakeOutbox()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "transaction initiated"})
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(txn)
 }
 
 func (h *Handler) ConfirmTransactionHandler(w http.ResponseWriter, r *http.Request) {
@@ -258,50 +440,259 @@ func (h *Handler) ConfirmTransactionHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Look up organization by name.
-	org, err := h.crudHandler.GetOrganizationByName(confirmReq.OrganizationName)
+	txn, err := h.confirmTransaction(sessionAddress(r), confirmReq)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.TransactionConfirmationResponse{
+		TransactionID: txn.ID,
+		Status:        txn.Status,
+		Confirmations: txn.Confirmations,
+		Threshold:     txn.Threshold,
+	})
+}
+
+func (h *Handler) RejectTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var rejectReq types.TransactionRejectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&rejectReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	txn, err := h.rejectTransaction(sessionAddress(r), rejectReq)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(txn)
+}
+
+// transactionProgress lists the participants that have approved, rejected or not yet decided on a transaction.
+func (h *Handler) transactionProgress(txn types.Transaction) (types.TransactionProgress, error) {
+	progress := types.TransactionProgress{
+		TransactionID:  txn.ID,
+		OrganizationID: txn.OrganizationID,
+		Confirmations:  txn.Confirmations,
+		Threshold:      txn.Threshold,
+		Approved:       []string{},
+		Rejected:       []string{},
+		Awaiting:       []string{},
+		Message:        fmt.Sprintf("Transaction confirmations: %d/%d", txn.Confirmations, txn.Threshold),
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(txn.OrganizationID)
+	if err != nil {
+		return progress, err
+	}
+	approvals, err := h.crudHandler.GetTransactionApprovals(txn.ID)
+	if err != nil {
+		return progress, err
+	}
+
+	rejections, err := h.crudHandler.GetTransactionRejections(txn.ID)
+	if err != nil {
+		return progress, err
+	}
+
+	decided := make(map[string]bool, len(approvals)+len(rejections))
+	for _, a := range approvals {
+		decided[a.Address] = true
+		progress.Approved = append(progress.Approved, a.Address)
+	}
+	for _, a := range rejections {
+		decided[a.Address] = true
+		progress.Rejected = append(progress.Rejected, a.Address)
+	}
+	for _, p := range org.Participants {
+		if !decided[p.Address] {
+			progress.Awaiting = append(progress.Awaiting, p.Address)
+		}
+	}
+
+	return progress, nil
+}
+
+func (h *Handler) CancelTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	var cancelReq types.TransactionCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&cancelReq); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	pending, err := h.crudHandler.GetTransaction(cancelReq.TransactionID)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+	address := sessionAddress(r)
+	if pending.Initiator != address {
+		http.Error(w, "Only the initiator can cancel a transaction", http.StatusForbidden)
+		return
+	}
+
+	txn, err := h.crudHandler.TransitionTransaction(pending.ID, types.TransactionCancelled, address)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	h.wakeOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(txn)
+}
+
+func (h *Handler) GetTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	orgName := r.URL.Query().Get("organization")
+	if orgName == "" {
+		http.Error(w, "Missing organization name", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.crudHandler.GetOrganizationByName(orgName)
 	if err != nil {
 		log.Error().Err(err).Msg("Organization not found")
 		http.Error(w, "Organization not found", http.StatusNotFound)
 		return
 	}
+	if !h.requireParticipant(w, org.ID, sessionAddress(r)) {
+		return
+	}
 
-	orgIDKey := fmt.Sprintf("%d", org.ID)
+	transactions, err := h.crudHandler.GetTransactionsByOrganization(org.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch transactions")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
 
-	// Increment confirmation count.
-	h.hub.mu.Lock()
-	txState, exists := h.hub.pendingTransactions[orgIDKey]
-	if !exists {
-		h.hub.mu.Unlock()
-		http.Error(w, "No pending transaction for this organization", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+func (h *Handler) GetTransactionHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid transaction id", http.StatusBadRequest)
 		return
 	}
-	txState.Confirmations++
-	currentConf := txState.Confirmations
-	h.hub.mu.Unlock()
 
-	// Optionally, notify all users about the update.
-	updateMsg := map[string]string{
-		"update": fmt.Sprintf("Transaction confirmations: %d/%d", currentConf, txState.Threshold),
+	txn, err := h.crudHandler.GetTransaction(id)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+	if !h.requireParticipant(w, txn.OrganizationID, sessionAddress(r)) {
+		return
+	}
+
+	history, err := h.crudHandler.GetTransactionHistory(id)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch transaction history")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
 	}
-	h.hub.BroadcastOrganization(orgIDKey, updateMsg)
 
-	// If threshold is reached, send a final notification.
-	if currentConf >= txState.Threshold {
-		finalNotif := types.TransactionNotification{
-			OrganizationID: org.ID,
-			Initiator:      txState.TransactionNotification.Initiator,
-			Details:        txState.TransactionNotification.Details,
-			Message:        "Transaction confirmed by threshold",
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func (h *Handler) GetTransactionApprovalsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	txn, err := h.crudHandler.GetTransaction(id)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+	if !h.requireParticipant(w, txn.OrganizationID, sessionAddress(r)) {
+		return
+	}
+
+	progress, err := h.transactionProgress(txn)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch transaction approvals")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// expireTransactions periodically moves stale pending transactions to expired.
+func (h *Handler) expireTransactions() {
+	ticker := time.NewTicker(h.txConf.ExpiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := h.crudHandler.ExpireTransactions()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to expire transactions")
+			continue
+		}
+		if len(expired) > 0 {
+			h.wakeOutbox()
 		}
-		h.hub.BroadcastOrganization(orgIDKey, finalNotif)
+	}
+}
 
-		// Remove the pending transaction after confirmation.
-		h.hub.mu.Lock()
-		delete(h.hub.pendingTransactions, orgIDKey)
-		h.hub.mu.Unlock()
+// requireParticipant writes a 403 and returns false unless address is a participant of the organization.
+func (h *Handler) requireParticipant(w http.ResponseWriter, orgID int, address string) bool {
+	member, err := h.crudHandler.IsParticipant(orgID, address)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check participant")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return false
+	}
+	if !member {
+		http.Error(w, crud.ErrNotParticipant.Error(), http.StatusForbidden)
+		return false
 	}
+	return true
+}
 
+// writeValidationError answers 422 with the field errors of a request. Other
+// errors are server errors.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		log.Error().Err(err).Msg("Validation error")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"confirmations": currentConf})
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(validation.Response{Error: "Validation failed", Fields: errs})
+}
+
+func writeTransactionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, crud.ErrTransactionNotFound):
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+	case errors.Is(err, crud.ErrNotParticipant):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, crud.ErrAlreadyConfirmed), errors.Is(err, crud.ErrDuplicateTransaction):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, crud.ErrIllegalTransition):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, crud.ErrMembershipConflict), errors.Is(err, crud.ErrProposalInFlight):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, crud.ErrResharing), errors.Is(err, crud.ErrReshareRequired), errors.Is(err, crud.ErrStaleKeyEpoch):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errInvalidSignatureEncoding), errors.Is(err, auth.ErrInvalidSignature), errors.Is(err, auth.ErrUnknownScheme):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Error().Err(err).Msg("Transaction error")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"mpc-backend/broker"
+	"mpc-backend/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutesRequireSession(t *testing.T) {
+	b, err := broker.NewLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(config.Configuration{}, nil, b)
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/hub/stats"},
+		{"GET", "/transactions?organization=acme"},
+		{"GET", "/transactions/1/history"},
+		{"GET", "/transactions/1/approvals"},
+		{"GET", "/organizations/1/keys"},
+		{"POST", "/organizations/1/keygen"},
+		{"POST", "/transaction/initiate"},
+		{"GET", "/ceremonies/abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status %d, want %d", rec.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -1,137 +1,618 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mpc-backend/broker"
+	"mpc-backend/config"
+	crud "mpc-backend/core"
 	"mpc-backend/types"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog/log"
 )
 
-// Connection represents a user websocket connection.
-type Connection struct {
-	Conn    *websocket.Conn
-	Address string
+// MessageStore keeps messages for addresses that are offline until they reconnect
+// and resolves organization membership for broadcasts and presence.
+type MessageStore interface {
+	EnqueueMessage(address string, outboxID int64, payload []byte) error
+	GetQueuedMessages(address string) ([]types.QueuedMessage, error)
+	GetParticipantAddresses(orgID int) ([]string, error)
+	GetOrganizationsByAddress(address string) ([]types.Organization, error)
+	AppendOrganizationEvent(orgID int, outboxID int64, frame types.Frame) (types.Frame, error)
+	GetOrganizationEvents(orgID int, after int64) ([]types.OrganizationEvent, error)
 }
 
-// TransactionState holds the details of a pending transaction.
-type TransactionState struct {
-	TransactionNotification types.TransactionNotification
-	Confirmations           int
-	Threshold               int
-}
-
-// Hub manages active websocket connections and organization rooms.
+// Hub manages active websocket connections and organization rooms. Messages
+// are published through the broker and every instance delivers them to its
+// own connections, so broadcasts reach clients connected to any instance.
 type Hub struct {
+	store  MessageStore
+	broker broker.Broker
+	conf   config.HubConf
+
+	// dropped and evicted count messages discarded and connections closed because of full send queues.
+	dropped atomic.Int64
+	evicted atomic.Int64
+
+	// forward handles client frames another instance could not handle itself.
+	forward func(conn *Connection, data []byte)
+
 	// mu protects the maps below.
 	mu sync.RWMutex
-	// mapping of user addresses to their websocket connections
-	connections map[string]*Connection
-	// mapping of organization IDs to a set of addresses connected to that room
-	orgRooms            map[string]map[string]*Connection
-	pendingTransactions map[string]*TransactionState
+	// mapping of user addresses to their websocket connections, keyed by connection ID
+	connections map[string]map[string]*Connection
+	// mapping of organization IDs to the connections in that room, keyed by connection ID
+	orgRooms map[string]map[string]*Connection
+	// when each address last closed its final connection on this instance
+	lastSeen map[string]time.Time
+}
+
+// NewHub creates a new Hub instance and starts receiving events from the broker.
+func NewHub(store MessageStore, b broker.Broker, conf config.HubConf) *Hub {
+	h := &Hub{
+		store:       store,
+		broker:      b,
+		conf:        conf,
+		connections: make(map[string]map[string]*Connection),
+		orgRooms:    make(map[string]map[string]*Connection),
+		lastSeen:    make(map[string]time.Time),
+	}
+	b.Listen(h.deliver)
+	return h
+}
+
+// SetForwardHandler sets the function that handles client frames forwarded
+// by other instances.
+func (h *Hub) SetForwardHandler(fn func(conn *Connection, data []byte)) {
+	h.forward = fn
 }
 
-// NewHub creates a new Hub instance.
-func NewHub() *Hub {
-	return &Hub{
-		connections:         make(map[string]*Connection),
-		orgRooms:            make(map[string]map[string]*Connection),
-		pendingTransactions: make(map[string]*TransactionState),
+// ID identifies this instance among the ones sharing the hub's events.
+func (h *Hub) ID() string {
+	return h.broker.ID()
+}
+
+// Clustered reports whether other instances share this hub's events.
+func (h *Hub) Clustered() bool {
+	return h.broker.Clustered()
+}
+
+// RegisterConnection registers a new WebSocket connection for an address
+// alongside any connections the address already has.
+func (h *Hub) RegisterConnection(address string, ws *websocket.Conn) (*Connection, error) {
+	id, err := randomToken(12)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := newConnection(id, address, wsTransport{conn: ws}, h.conf.SendQueueSize)
+	conn.Conn = ws
+	conn.keepAlive(h.conf.PongTimeout, h.conf.MaxMessageSize)
+	h.register(conn)
+	return conn, nil
+}
+
+// RegisterStream registers a new Server-Sent Events stream for an address.
+// Broadcasts reach it exactly like a WebSocket connection.
+func (h *Hub) RegisterStream(address string, t *sseTransport) (*Connection, error) {
+	id, err := randomToken(12)
+	if err != nil {
+		return nil, err
 	}
+
+	conn := newConnection(id, address, t, h.conf.SendQueueSize)
+	h.register(conn)
+	return conn, nil
 }
 
-// RegisterConnection registers a connection by address.
-func (h *Hub) RegisterConnection(address string, ws *websocket.Conn) {
+// register starts the write pump of a connection and adds it to the hub. The
+// first connection of an address across the cluster announces it as online.
+func (h *Hub) register(conn *Connection) {
+	address, id := conn.Address, conn.ID
+	conn.undelivered = func(msg []byte) { h.spill(address, msg) }
+	go conn.writePump(h.conf.WriteTimeout, h.conf.PingInterval)
+
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	conn := &Connection{Conn: ws, Address: address}
-	h.connections[address] = conn
-	log.Printf("Registered connection for address: %s", address)
+	if h.connections[address] == nil {
+		h.connections[address] = make(map[string]*Connection)
+	}
+	h.connections[address][id] = conn
+	h.mu.Unlock()
+	log.Printf("Registered connection %s for address: %s", id, address)
+
+	total, err := h.broker.Join(address)
+	if err != nil {
+		log.Error().Err(err).Str("address", address).Msg("Failed to record presence")
+	} else if total == 1 {
+		h.announcePresence(address, true)
+	}
 }
 
-// UnregisterConnection removes a connection from the hub and all rooms.
-func (h *Hub) UnregisterConnection(address string) {
+// UnregisterConnection closes a single connection and removes it from the hub and
+// all rooms. Other connections of the same address are left untouched.
+func (h *Hub) UnregisterConnection(conn *Connection) {
+	conn.Close()
+
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	delete(h.connections, address)
+	removed := false
+	if conns, ok := h.connections[conn.Address]; ok {
+		if _, ok := conns[conn.ID]; ok {
+			removed = true
+			delete(conns, conn.ID)
+			if len(conns) == 0 {
+				delete(h.connections, conn.Address)
+				h.lastSeen[conn.Address] = time.Now().UTC()
+			}
+		}
+	}
 	// Remove from all organization rooms
+	var left []string
 	for orgID, members := range h.orgRooms {
-		delete(members, address)
+		if _, ok := members[conn.ID]; ok {
+			left = append(left, orgID)
+			delete(members, conn.ID)
+		}
 		// remove empty rooms
 		if len(members) == 0 {
 			delete(h.orgRooms, orgID)
 		}
 	}
-	log.Printf("Unregistered connection for address: %s", address)
+	h.mu.Unlock()
+	for _, orgID := range left {
+		h.unsubscribe(orgID, conn.Address)
+	}
+	if !removed {
+		return
+	}
+	log.Printf("Unregistered connection %s for address: %s", conn.ID, conn.Address)
+
+	total, err := h.broker.Leave(conn.Address)
+	if err != nil {
+		log.Error().Err(err).Str("address", conn.Address).Msg("Failed to record presence")
+	} else if total == 0 {
+		h.announcePresence(conn.Address, false)
+	}
 }
 
 // JoinOrganizationRoom adds a connection to an organization room.
-func (h *Hub) JoinOrganizationRoom(orgID, address string) {
+func (h *Hub) JoinOrganizationRoom(orgID string, conn *Connection) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	conn, ok := h.connections[address]
-	if !ok {
-		log.Printf("No connection for address: %s", address)
+	if _, ok := h.connections[conn.Address][conn.ID]; !ok {
+		h.mu.Unlock()
+		log.Printf("Connection %s is not registered", conn.ID)
 		return
 	}
 	if h.orgRooms[orgID] == nil {
 		h.orgRooms[orgID] = make(map[string]*Connection)
 	}
-	h.orgRooms[orgID][address] = conn
-	log.Printf("Address %s joined organization room: %s", address, orgID)
+	_, joined := h.orgRooms[orgID][conn.ID]
+	h.orgRooms[orgID][conn.ID] = conn
+	h.mu.Unlock()
+	if joined {
+		return
+	}
+	log.Printf("Address %s joined organization room: %s", conn.Address, orgID)
+
+	if err := h.broker.Subscribe(orgID, conn.Address); err != nil {
+		log.Error().Err(err).Str("address", conn.Address).Str("organization", orgID).Msg("Failed to record room subscription")
+	}
 }
 
 // LeaveOrganizationRoom removes a connection from an organization room.
-func (h *Hub) LeaveOrganizationRoom(orgID, address string) {
+func (h *Hub) LeaveOrganizationRoom(orgID string, conn *Connection) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	if members, ok := h.orgRooms[orgID]; ok {
-		delete(members, address)
+	members := h.orgRooms[orgID]
+	_, left := members[conn.ID]
+	if left {
+		delete(members, conn.ID)
 		if len(members) == 0 {
 			delete(h.orgRooms, orgID)
 		}
-		log.Printf("Address %s left organization room: %s", address, orgID)
 	}
+	h.mu.Unlock()
+	if !left {
+		return
+	}
+	log.Printf("Address %s left organization room: %s", conn.Address, orgID)
+	h.unsubscribe(orgID, conn.Address)
 }
 
-// NotifyUser sends a message to a specific user connection.
-func (h *Hub) NotifyUser(address string, message interface{}) {
+func (h *Hub) unsubscribe(orgID, address string) {
+	if err := h.broker.Unsubscribe(orgID, address); err != nil {
+		log.Error().Err(err).Str("address", address).Str("organization", orgID).Msg("Failed to record room subscription")
+	}
+}
+
+// IsOnline reports whether an address has at least one open connection on any instance.
+func (h *Hub) IsOnline(address string) bool {
 	h.mu.RLock()
-	conn, ok := h.connections[address]
+	local := len(h.connections[address]) > 0
 	h.mu.RUnlock()
-	if !ok {
+	if local {
+		return true
+	}
+
+	online, err := h.broker.Online([]string{address})
+	if err != nil {
+		log.Error().Err(err).Str("address", address).Msg("Failed to check presence")
+		return false
+	}
+	return online[address]
+}
+
+// Presence reports which of the given addresses are online and when the
+// offline ones were last seen on this instance, if they connected since it started.
+func (h *Hub) Presence(addresses []string) []types.ParticipantPresence {
+	online, err := h.broker.Online(addresses)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch presence")
+		online = map[string]bool{}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	presence := make([]types.ParticipantPresence, 0, len(addresses))
+	for _, addr := range addresses {
+		p := types.ParticipantPresence{Address: addr, Online: online[addr] || len(h.connections[addr]) > 0}
+		if seen, ok := h.lastSeen[addr]; ok && !p.Online {
+			p.LastSeen = &seen
+		}
+		presence = append(presence, p)
+	}
+	return presence
+}
+
+// NotifyUser sends the message of an outbox event to every connection of a
+// user, queueing it if the user is offline or a connection does not take it.
+// The frame ID is derived from the
+// event, so clients can drop a message that is sent again when the outbox
+// retries the event.
+func (h *Hub) NotifyUser(address string, eventID int64, message interface{}) error {
+	frame, err := newFrame(message)
+	if err != nil {
+		return err
+	}
+	frame.ID = eventFrameID(eventID)
+	if h.NotifyUserOnline(address, frame) {
+		return nil
+	}
+	return h.enqueue(address, eventID, frame)
+}
+
+// NotifyUserOnline sends a message to every connection of a user and drops it if the
+// user is offline. It is meant for messages that are worthless after the fact.
+func (h *Hub) NotifyUserOnline(address string, message interface{}) bool {
+	if !h.IsOnline(address) {
 		log.Printf("No connection for address: %s", address)
-		return
+		return false
 	}
-	if err := conn.Conn.WriteJSON(message); err != nil {
-		log.Printf("Error sending message to %s: %v", address, err)
+	msg, err := encodeFrame(message)
+	if err != nil {
+		log.Error().Err(err).Str("address", address).Msg("Failed to encode message")
+		return true
 	}
+	h.publish(broker.Event{Kind: broker.KindUser, Address: address, Frame: msg})
+	return true
 }
 
-// BroadcastOrganization sends a message to all connections in an organization room.
+// BroadcastOrganization stores a message as the next event of the organization,
+// sends it to all connections in the organization room and queues it for
+// participants of the organization that have no connection in its room.
 func (h *Hub) BroadcastOrganization(orgID string, message interface{}) {
+	id, err := strconv.Atoi(orgID)
+	if err != nil {
+		h.notifyRoom(orgID, message)
+		return
+	}
+	if err := h.broadcast(id, 0, message); err != nil {
+		log.Error().Err(err).Str("organization", orgID).Msg("Failed to broadcast organization event")
+	}
+}
+
+// PublishOrganizationEvent broadcasts the message of an outbox event like
+// BroadcastOrganization, but fails instead of delivering what it cannot store.
+// Publishing the same event again reuses its sequence number and frame ID and
+// does not queue it twice, so the outbox can safely retry it.
+func (h *Hub) PublishOrganizationEvent(orgID int, eventID int64, message interface{}) error {
+	return h.broadcast(orgID, eventID, message)
+}
+
+func (h *Hub) broadcast(orgID int, eventID int64, message interface{}) error {
+	frame, err := newFrame(message)
+	if err != nil {
+		return err
+	}
+	if eventID != 0 {
+		frame.ID = eventFrameID(eventID)
+	}
+	frame.OrganizationID = orgID
+
+	sequenced, err := h.store.AppendOrganizationEvent(orgID, eventID, frame)
+	switch {
+	case err == nil:
+		frame = sequenced
+	case eventID != 0:
+		return err
+	default:
+		// An event that cannot be stored is still worth delivering live.
+		log.Error().Err(err).Int("organization", orgID).Msg("Failed to store organization event")
+	}
+	room := strconv.Itoa(orgID)
+	h.notifyRoom(room, frame)
+
+	// Participants that are connected without a connection in the room miss
+	// the live frame just like offline ones, so they get a queued copy too.
+	participants, err := h.store.GetParticipantAddresses(orgID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch participants for offline delivery: %w", err)
+	}
+	subscribed, err := h.broker.Subscribed(room, participants)
+	if err != nil {
+		return fmt.Errorf("failed to fetch room subscriptions for offline delivery: %w", err)
+	}
+	for _, addr := range participants {
+		if !subscribed[addr] {
+			if err := h.enqueue(addr, eventID, frame); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ResumeOrganizationRoom adds a connection to an organization room and first
+// sends it the events of the organization after lastSeq. Live events arriving
+// meanwhile are held back and sent afterwards, so the connection sees events
+// in order. If the missed events are no longer retained, a resync_required
+// frame is sent instead.
+func (h *Hub) ResumeOrganizationRoom(orgID int, conn *Connection, lastSeq int64) {
+	room := strconv.Itoa(orgID)
+	conn.hold(room)
+	h.JoinOrganizationRoom(room, conn)
+
+	last := lastSeq
+	events, err := h.store.GetOrganizationEvents(orgID, lastSeq)
+	switch {
+	case errors.Is(err, crud.ErrEventsPruned):
+		h.Send(conn, types.ResyncRequired{OrganizationID: orgID, LastSeq: lastSeq})
+	case err != nil:
+		log.Error().Err(err).Int("organization", orgID).Msg("Failed to fetch missed organization events")
+	default:
+		for _, e := range events {
+			if err := conn.wait(e.Frame, h.conf.WriteTimeout); err != nil {
+				log.Printf("Error resuming %s on connection %s: %v", conn.Address, conn.ID, err)
+				break
+			}
+			last = e.Seq
+		}
+	}
+
+	for _, frame := range conn.release(room, last) {
+		h.push(conn, frame)
+	}
+}
+
+// ReplayQueued sends every queued message of the connection's address to that
+// connection in order. Messages stay queued until the client acknowledges them.
+func (h *Hub) ReplayQueued(conn *Connection) {
+	messages, err := h.store.GetQueuedMessages(conn.Address)
+	if err != nil {
+		log.Error().Err(err).Str("address", conn.Address).Msg("Failed to fetch queued messages")
+		return
+	}
+	for _, m := range messages {
+		msg, err := encodeFrame(m)
+		if err != nil {
+			log.Error().Err(err).Int64("message", m.ID).Msg("Failed to encode queued message")
+			continue
+		}
+		// Replay waits for room instead of applying the slow consumer policy, so a
+		// long backlog is not dropped by its own size.
+		if err := conn.wait(msg, h.conf.WriteTimeout); err != nil {
+			log.Printf("Error replaying message to %s on connection %s: %v", conn.Address, conn.ID, err)
+			return
+		}
+	}
+}
+
+// Send writes a message to a single connection, e.g. the reply to one of its
+// requests. Connections of other instances are reached through the broker.
+func (h *Hub) Send(conn *Connection, message interface{}) {
+	msg, err := encodeFrame(message)
+	if err != nil {
+		log.Error().Err(err).Str("connection", conn.ID).Msg("Failed to encode frame")
+		return
+	}
+	if conn.instance != "" {
+		h.publish(broker.Event{Kind: broker.KindConnection, Instance: conn.instance, Connection: conn.ID, Frame: msg})
+		return
+	}
+	h.push(conn, msg)
+}
+
+// Forward hands a client frame to another instance, e.g. the one running the
+// ceremony session it belongs to.
+func (h *Hub) Forward(conn *Connection, instance string, data []byte) {
+	h.publish(broker.Event{Kind: broker.KindForward, Instance: instance, Address: conn.Address, Connection: conn.ID, Frame: data})
+}
+
+// Stats reports the number of open connections and the slow consumer counters.
+func (h *Hub) Stats() types.HubStats {
 	h.mu.RLock()
-	members, ok := h.orgRooms[orgID]
+	connections := 0
+	for _, conns := range h.connections {
+		connections += len(conns)
+	}
 	h.mu.RUnlock()
-	if !ok {
-		log.Printf("No room found for organization: %s", orgID)
+
+	return types.HubStats{
+		Connections: connections,
+		Dropped:     h.dropped.Load(),
+		Evicted:     h.evicted.Load(),
+	}
+}
+
+// notifyRoom sends a message to the connections currently in an organization room.
+func (h *Hub) notifyRoom(orgID string, message interface{}) {
+	frame, err := newFrame(message)
+	if err != nil {
+		log.Error().Err(err).Str("organization", orgID).Msg("Failed to encode message")
+		return
+	}
+	msg, err := json.Marshal(frame)
+	if err != nil {
+		log.Error().Err(err).Str("organization", orgID).Msg("Failed to encode message")
+		return
+	}
+	h.publish(broker.Event{Kind: broker.KindRoom, Room: orgID, Seq: frame.Seq, Frame: msg})
+}
+
+// announcePresence tells the rooms of every organization the address belongs
+// to that it came online or went offline. Presence is not queued for offline
+// participants since it is stale by the time they reconnect.
+func (h *Hub) announcePresence(address string, online bool) {
+	orgs, err := h.store.GetOrganizationsByAddress(address)
+	if err != nil {
+		log.Error().Err(err).Str("address", address).Msg("Failed to fetch organizations for presence")
+		return
+	}
+	for _, org := range orgs {
+		h.notifyRoom(strconv.Itoa(org.ID), types.PresenceEvent{
+			Event:          "presence",
+			OrganizationID: org.ID,
+			Address:        address,
+			Online:         online,
+		})
+	}
+}
+
+func (h *Hub) publish(e broker.Event) {
+	e.Origin = h.broker.ID()
+	if err := h.broker.Publish(e); err != nil {
+		log.Error().Err(err).Str("kind", e.Kind).Msg("Failed to publish hub event")
+	}
+}
+
+// deliver hands an event from the broker to the matching connections of this instance.
+func (h *Hub) deliver(e broker.Event) {
+	var targets []*Connection
+
+	h.mu.RLock()
+	switch e.Kind {
+	case broker.KindUser:
+		for _, conn := range h.connections[e.Address] {
+			targets = append(targets, conn)
+		}
+	case broker.KindRoom:
+		for _, conn := range h.orgRooms[e.Room] {
+			if !conn.buffer(e.Room, e.Seq, e.Frame) {
+				targets = append(targets, conn)
+			}
+		}
+	case broker.KindConnection:
+		if e.Instance == h.broker.ID() {
+			for _, conns := range h.connections {
+				if conn, ok := conns[e.Connection]; ok {
+					targets = append(targets, conn)
+				}
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	if e.Kind == broker.KindForward {
+		if e.Origin != h.broker.ID() && e.Instance == h.broker.ID() && h.forward != nil {
+			h.forward(&Connection{ID: e.Connection, Address: e.Address, instance: e.Origin}, e.Frame)
+		}
 		return
 	}
-	for addr, conn := range members {
-		if err := conn.Conn.WriteJSON(message); err != nil {
-			log.Printf("Error sending message to %s in room %s: %v", addr, orgID, err)
+
+	for _, conn := range targets {
+		h.push(conn, e.Frame)
+	}
+}
+
+// push queues a message on a connection without blocking. When the queue is
+// full the configured slow consumer policy decides what gives. Messages that
+// do not reach the connection are spilled to the offline queue.
+func (h *Hub) push(conn *Connection, msg []byte) {
+	for {
+		select {
+		case conn.send <- msg:
+			return
+		case <-conn.done:
+			h.spill(conn.Address, msg)
+			return
+		default:
+		}
+
+		if h.conf.SlowConsumerPolicy == PolicyDisconnect {
+			// The write pump closes the connection and spills what is still queued.
+			if conn.evict() {
+				h.evicted.Add(1)
+				log.Warn().Str("address", conn.Address).Str("connection", conn.ID).Msg("Disconnecting slow consumer")
+			}
+			h.spill(conn.Address, msg)
+			return
+		}
+
+		select {
+		case dropped := <-conn.send:
+			h.dropped.Add(1)
+			h.spill(conn.Address, dropped)
+		default:
 		}
 	}
 }
 
-func (h *Hub) RegisterPendingTransaction(orgID string, notif types.TransactionNotification, threshold int) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.pendingTransactions[orgID] = &TransactionState{
-		TransactionNotification: notif,
-		Confirmations:           0,
-		Threshold:               threshold,
+// spill queues a message an online address did not receive, so it is replayed
+// on its next connection. Only organization and outbox events are worth it;
+// replies, presence and ceremony traffic are stale by then.
+func (h *Hub) spill(address string, msg []byte) {
+	var frame types.Frame
+	if err := json.Unmarshal(msg, &frame); err != nil {
+		return
+	}
+	eventID, outbox := parseEventFrameID(frame.ID)
+	if !outbox && frame.Seq == 0 {
+		return
+	}
+	if err := h.store.EnqueueMessage(address, eventID, msg); err != nil {
+		log.Error().Err(err).Str("address", address).Msg("Failed to queue undelivered message")
+	}
+}
+
+// enqueue stores a message for an address that cannot receive it live.
+// eventID is the outbox event the message belongs to, or 0.
+func (h *Hub) enqueue(address string, eventID int64, message interface{}) error {
+	payload, err := encodeFrame(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode message for queueing: %w", err)
+	}
+	return h.store.EnqueueMessage(address, eventID, payload)
+}
+
+// eventFrameID is the frame ID of messages sent for an outbox event.
+func eventFrameID(eventID int64) string {
+	return "evt-" + strconv.FormatInt(eventID, 10)
+}
+
+// parseEventFrameID returns the outbox event a frame ID was derived from.
+func parseEventFrameID(id string) (int64, bool) {
+	rest, ok := strings.CutPrefix(id, "evt-")
+	if !ok {
+		return 0, false
+	}
+	eventID, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || eventID <= 0 {
+		return 0, false
 	}
-	log.Printf("Registered pending transaction for org %s with threshold %d", orgID, threshold)
+	return eventID, true
 }
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"encoding/json"
+	"mpc-backend/config"
+	"mpc-backend/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func eventFrame(t *testing.T, id string, seq int64) []byte {
+	t.Helper()
+	msg, err := json.Marshal(types.Frame{Version: types.ProtocolVersion, Type: types.FrameTransactionNotification, ID: id, Seq: seq})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// queuedIDs returns the frame IDs queued for an address.
+func queuedIDs(t *testing.T, store *fakeStore, address string) []string {
+	t.Helper()
+	messages, _ := store.GetQueuedMessages(address)
+	var ids []string
+	for _, m := range messages {
+		var f types.Frame
+		if err := json.Unmarshal(m.Payload, &f); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, f.ID)
+	}
+	return ids
+}
+
+func TestParseEventFrameID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want int64
+		ok   bool
+	}{
+		{eventFrameID(42), 42, true},
+		{"evt-0", 0, false},
+		{"evt-x", 0, false},
+		{"abc", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseEventFrameID(tt.id)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseEventFrameID(%q) = %d, %v, want %d, %v", tt.id, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSpill(t *testing.T) {
+	hub, store := newTestHub(t, config.HubConf{SendQueueSize: 1})
+
+	hub.spill("a", eventFrame(t, eventFrameID(7), 0))
+	hub.spill("a", eventFrame(t, "room-event", 3))
+	// Replies, presence and ceremony traffic carry neither.
+	hub.spill("a", eventFrame(t, "reply", 0))
+	hub.spill("a", []byte("not a frame"))
+
+	if got, want := queuedIDs(t, store, "a"), []string{"evt-7", "room-event"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("queued %v, want %v", got, want)
+	}
+	if got, want := store.outboxIDs["a"], []int64{7, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("queued outbox events %v, want %v", got, want)
+	}
+}
+
+func TestPushDropOldest(t *testing.T) {
+	hub, store := newTestHub(t, config.HubConf{SendQueueSize: 2, SlowConsumerPolicy: PolicyDropOldest})
+	conn := newConnection("c", "a", &fakeTransport{}, 2)
+
+	for i := int64(1); i <= 4; i++ {
+		hub.push(conn, eventFrame(t, eventFrameID(i), 0))
+	}
+
+	var live []string
+	for _, f := range sent(t, conn) {
+		live = append(live, f.ID)
+	}
+	if want := []string{"evt-3", "evt-4"}; !reflect.DeepEqual(live, want) {
+		t.Fatalf("send queue holds %v, want %v", live, want)
+	}
+	if got, want := queuedIDs(t, store, "a"), []string{"evt-1", "evt-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("queued %v, want the dropped %v", got, want)
+	}
+	if stats := hub.Stats(); stats.Dropped != 2 || stats.Evicted != 0 {
+		t.Fatalf("stats %+v, want 2 dropped", stats)
+	}
+}
+
+// blockingTransport holds every write until it is released with its result.
+type blockingTransport struct {
+	fakeTransport
+	writing chan []byte
+	release chan error
+}
+
+func (b *blockingTransport) write(frame []byte, _ time.Duration) error {
+	b.writing <- frame
+	return <-b.release
+}
+
+func TestPushDisconnect(t *testing.T) {
+	hub, store := newTestHub(t, config.HubConf{SendQueueSize: 1, SlowConsumerPolicy: PolicyDisconnect, PingInterval: time.Minute})
+	transport := &blockingTransport{writing: make(chan []byte), release: make(chan error)}
+	conn := newConnection("c", "a", transport, 1)
+	hub.register(conn)
+
+	hub.push(conn, eventFrame(t, eventFrameID(1), 0))
+	<-transport.writing
+	hub.push(conn, eventFrame(t, eventFrameID(2), 0))
+	hub.push(conn, eventFrame(t, eventFrameID(3), 0))
+	hub.push(conn, eventFrame(t, eventFrameID(4), 0))
+
+	// The write pump, not the pusher, closes the connection once its write returns.
+	select {
+	case <-conn.Done():
+		t.Fatal("connection closed before the write pump noticed the eviction")
+	default:
+	}
+	transport.release <- errConnectionClosed
+
+	select {
+	case <-conn.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("write pump did not stop after the eviction")
+	}
+	if !transport.closed {
+		t.Error("transport left open after the eviction")
+	}
+	if got, want := queuedIDs(t, store, "a"), []string{"evt-3", "evt-4", "evt-1", "evt-2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("queued %v, want %v", got, want)
+	}
+	if stats := hub.Stats(); stats.Evicted != 1 || stats.Dropped != 0 {
+		t.Fatalf("stats %+v, want 1 eviction", stats)
+	}
+	// Unregistering is left to whoever reads from the connection.
+	if !hub.IsOnline("a") {
+		t.Error("evicted connection was unregistered by the pusher")
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) GetInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	status := types.InvitationStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", types.InvitationPending, types.InvitationAccepted, types.InvitationDeclined, types.InvitationExpired:
+	default:
+		http.Error(w, "Invalid invitation status", http.StatusBadRequest)
+		return
+	}
+
+	invitations, err := h.crudHandler.GetInvitationsByAddress(sessionAddress(r), status)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch invitations")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+func (h *Handler) AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	h.respondToInvitation(w, r, true)
+}
+
+func (h *Handler) DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	h.respondToInvitation(w, r, false)
+}
+
+func (h *Handler) respondToInvitation(w http.ResponseWriter, r *http.Request, accept bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid invitation id", http.StatusBadRequest)
+		return
+	}
+
+	inv, err := h.crudHandler.RespondToInvitation(id, sessionAddress(r), accept)
+	if err != nil {
+		writeInvitationError(w, err)
+		return
+	}
+	h.wakeOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(inv)
+}
+
+func (h *Handler) GetOrganizationInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.crudHandler.GetOrganizationInvitations(org.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch invitations")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invitations)
+}
+
+// handleInvitationEvent tells an invitee about a new invitation, and the
+// organization and its admin about an invitation that was answered or expired.
+func (h *Handler) handleInvitationEvent(e types.OutboxEvent, inv types.Invitation) error {
+	if e.Event == types.EventInvitationCreated {
+		return h.hub.NotifyUser(inv.Address, e.ID, types.InvitationMessage{
+			InvitationID:     inv.ID,
+			OrganizationID:   inv.OrganizationID,
+			OrganizationName: inv.OrganizationName,
+			Message:          fmt.Sprintf("You've been invited to join organization %s", inv.OrganizationName),
+			ExpiresAt:        inv.ExpiresAt,
+		})
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(inv.OrganizationID)
+	if err != nil {
+		return err
+	}
+	update := types.InvitationUpdate{
+		InvitationID:   inv.ID,
+		OrganizationID: org.ID,
+		Address:        inv.Address,
+		Status:         inv.Status,
+		Accepted:       len(org.Participants),
+		Threshold:      org.Threshold,
+		Ready:          org.Ready,
+	}
+	if err := h.hub.PublishOrganizationEvent(org.ID, e.ID, update); err != nil {
+		return err
+	}
+
+	// The admin does not necessarily sign, and then is not in the organization's room.
+	for _, p := range org.Participants {
+		if p.Address == org.Admin {
+			return nil
+		}
+	}
+	if org.Admin == "" {
+		return nil
+	}
+	return h.hub.NotifyUser(org.Admin, e.ID, update)
+}
+
+// expireInvitations periodically moves stale pending invitations to expired.
+func (h *Handler) expireInvitations() {
+	ticker := time.NewTicker(h.inviteConf.ExpiryInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := h.crudHandler.ExpireInvitations()
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to expire invitations")
+			continue
+		}
+		if len(expired) > 0 {
+			h.wakeOutbox()
+		}
+	}
+}
+
+func writeInvitationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, crud.ErrInvitationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, crud.ErrInvitationNotPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error().Err(err).Msg("Invitation error")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+	}
+}
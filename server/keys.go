@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mpc-backend/auth"
+	crud "mpc-backend/core"
+	"mpc-backend/envelope"
+	"mpc-backend/types"
+	"mpc-backend/validation"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) SetEncryptionKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req types.EncryptionKey
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	raw, err := auth.DecodeHex(req.PublicKey)
+	if err == nil {
+		_, err = envelope.ParsePublicKey(raw)
+	}
+	if err != nil {
+		http.Error(w, "public_key must be a hex encoded X25519 public key", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.crudHandler.SetEncryptionKey(sessionAddress(r), hex.EncodeToString(raw))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to store encryption key")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+func (h *Handler) GetEncryptionKeyHandler(w http.ResponseWriter, r *http.Request) {
+	address, err := h.addresses.Normalize(mux.Vars(r)["address"])
+	if err != nil {
+		writeValidationError(w, validation.Errors{{Field: "address", Message: err.Error()}})
+		return
+	}
+
+	key, err := h.crudHandler.GetEncryptionKey(address)
+	if err != nil {
+		if errors.Is(err, crud.ErrEncryptionKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to fetch encryption key")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
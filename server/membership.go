@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	crud "mpc-backend/core"
+	"mpc-backend/payload"
+	"mpc-backend/types"
+	"mpc-backend/validation"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// ProposeMembershipHandler opens a proposal to invite or remove participants or
+// to change the threshold. Participants approve or reject it like a transaction,
+// signing its decision message, and it is applied once the threshold approves.
+func (h *Handler) ProposeMembershipHandler(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid organization id", http.StatusBadRequest)
+		return
+	}
+
+	var req types.MembershipProposalRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Organization not found")
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	initiator := sessionAddress(r)
+	if !h.requireParticipant(w, org.ID, initiator) {
+		return
+	}
+
+	var errs validation.Errors
+	req.Add = validation.NormalizeAddresses("add", req.Add, h.addresses, &errs)
+	req.Remove = validation.NormalizeAddresses("remove", req.Remove, h.addresses, &errs)
+	if err := errs.Err(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	proposal, err := payload.Normalize(types.TransactionPayload{
+		Type: payload.TypeMembership,
+		Membership: &types.MembershipPayload{
+			OrganizationID: org.ID,
+			Add:            req.Add,
+			Remove:         req.Remove,
+			Threshold:      req.Threshold,
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := crud.CheckMembershipChange(org, *proposal.Membership); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	hash, err := payload.Hash(proposal)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	txn, err := h.crudHandler.CreateTransaction(types.Transaction{
+		OrganizationID: org.ID,
+		Initiator:      initiator,
+		Details:        req.Details,
+		Payload:        proposal,
+		PayloadHash:    payload.HexHash(hash),
+		Threshold:      org.Threshold,
+		ExpiresAt:      time.Now().Add(h.txConf.TTL),
+	})
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+	h.wakeOutbox()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(txn)
+}
+
+// handleMembershipChange tells the organization, and the participants that
+// were removed from it, about an applied membership change.
+func (h *Handler) handleMembershipChange(e types.OutboxEvent, change types.MembershipChange) error {
+	if err := h.hub.PublishOrganizationEvent(change.OrganizationID, e.ID, change); err != nil {
+		return err
+	}
+	for _, addr := range change.Removed {
+		if err := h.hub.NotifyUser(addr, e.ID, change); err != nil {
+			return fmt.Errorf("failed to notify removed participant %s: %w", addr, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"mpc-backend/wallet"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) GetOrganizationKeysHandler(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid organization id", http.StatusBadRequest)
+		return
+	}
+	if !h.requireParticipant(w, orgID, sessionAddress(r)) {
+		return
+	}
+
+	keys, err := h.organizationKeys(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch organization keys")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// CreateKeyAccountHandler lets the admin add an account of an organization key
+// at a non-hardened BIP32 path.
+func (h *Handler) CreateKeyAccountHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	keyID, err := strconv.Atoi(mux.Vars(r)["key"])
+	if err != nil {
+		http.Error(w, "Invalid key id", http.StatusBadRequest)
+		return
+	}
+
+	var req types.CreateKeyAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	path, err := wallet.ParsePath(req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.crudHandler.GetOrganizationKey(org.ID, keyID)
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+	pub, err := wallet.ParsePublicKey(key.PublicKey)
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+	child, err := wallet.Derive(pub, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	account, err := h.crudHandler.CreateKeyAccount(key.ID, wallet.FormatPath(path), strings.TrimSpace(req.Label), wallet.EncodePublicKey(child))
+	if err != nil {
+		writeKeyError(w, err)
+		return
+	}
+	if account.Addresses, err = wallet.Addresses(child, h.keyConf.BitcoinNetwork); err != nil {
+		writeKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(account)
+}
+
+// organizationKeys returns the keys of an organization and their accounts
+// together with their addresses on every supported chain.
+func (h *Handler) organizationKeys(orgID int) ([]types.OrganizationKey, error) {
+	keys, err := h.crudHandler.GetOrganizationKeys(orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		if keys[i].Addresses, err = chainAddresses(keys[i].PublicKey, h.keyConf.BitcoinNetwork); err != nil {
+			return nil, err
+		}
+		for j := range keys[i].Accounts {
+			a := &keys[i].Accounts[j]
+			if a.Addresses, err = chainAddresses(a.PublicKey, h.keyConf.BitcoinNetwork); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return keys, nil
+}
+
+func chainAddresses(publicKey, network string) (types.ChainAddresses, error) {
+	pub, err := wallet.ParsePublicKey(publicKey)
+	if err != nil {
+		return types.ChainAddresses{}, err
+	}
+	return wallet.Addresses(pub, network)
+}
+
+func writeKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, crud.ErrKeyNotFound), errors.Is(err, crud.ErrAccountNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, crud.ErrAccountExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error().Err(err).Msg("Organization key error")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+	}
+}
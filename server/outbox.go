@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mpc-backend/types"
+	"mpc-backend/webhooks"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// wakeOutbox makes the outbox dispatcher look for new events right away
+// instead of waiting for its next poll. It never blocks.
+func (h *Handler) wakeOutbox() {
+	select {
+	case h.outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchOutbox hands committed domain events to the hub, webhooks and the
+// offline queue. Events are delivered at least once: an event is only marked
+// dispatched after every consumer took it, and consumers recognise events they
+// have already seen by their outbox ID.
+func (h *Handler) dispatchOutbox() {
+	ticker := time.NewTicker(h.outboxConf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.outboxWake:
+		}
+
+		// Only the oldest pending event of an organization is claimed at a time,
+		// so keep claiming until nothing is due.
+		for {
+			events, err := h.crudHandler.ClaimOutboxEvents(h.outboxConf.BatchSize, h.outboxConf.Lease)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to claim outbox events")
+				break
+			}
+			if len(events) == 0 {
+				break
+			}
+			for _, e := range events {
+				h.dispatchOutboxEvent(e)
+			}
+		}
+	}
+}
+
+func (h *Handler) dispatchOutboxEvent(e types.OutboxEvent) {
+	err := h.handleOutboxEvent(e)
+	if err == nil {
+		if err := h.crudHandler.RecordOutboxAttempt(e.ID, types.OutboxDispatched, "", time.Now()); err != nil {
+			log.Error().Err(err).Int64("event", e.ID).Msg("Failed to record outbox dispatch")
+		}
+		return
+	}
+
+	attempts := e.Attempts + 1
+	log.Warn().Err(err).Int64("event", e.ID).Str("kind", e.Event).Int("attempts", attempts).Msg("Outbox dispatch failed")
+
+	status, next := outboxRetry(attempts, h.outboxConf.MaxAttempts, h.outboxConf.RetryInterval, time.Now())
+	if err := h.crudHandler.RecordOutboxAttempt(e.ID, status, err.Error(), next); err != nil {
+		log.Error().Err(err).Int64("event", e.ID).Msg("Failed to record outbox attempt")
+	}
+}
+
+// outboxRetry decides what becomes of an event after a failed dispatch: it
+// is retried with a linearly growing delay until maxAttempts were made.
+func outboxRetry(attempts, maxAttempts int, interval time.Duration, now time.Time) (types.OutboxStatus, time.Time) {
+	status := types.OutboxPending
+	if attempts >= maxAttempts {
+		status = types.OutboxFailed
+	}
+	return status, now.Add(time.Duration(attempts) * interval)
+}
+
+// handleOutboxEvent delivers a single event to every consumer.
+func (h *Handler) handleOutboxEvent(e types.OutboxEvent) error {
+	switch e.Event {
+	case types.EventInvitationCreated, types.EventInvitationAccepted, types.EventInvitationDeclined, types.EventInvitationExpired:
+		var inv types.Invitation
+		if err := json.Unmarshal(e.Payload, &inv); err != nil {
+			return fmt.Errorf("failed to decode invitation: %w", err)
+		}
+		return h.handleInvitationEvent(e, inv)
+	case types.EventMembershipChanged:
+		var change types.MembershipChange
+		if err := json.Unmarshal(e.Payload, &change); err != nil {
+			return fmt.Errorf("failed to decode membership change: %w", err)
+		}
+		return h.handleMembershipChange(e, change)
+	case types.EventKeyReshared:
+		var reshared types.KeyReshared
+		if err := json.Unmarshal(e.Payload, &reshared); err != nil {
+			return fmt.Errorf("failed to decode key reshare: %w", err)
+		}
+		return h.hub.PublishOrganizationEvent(reshared.OrganizationID, e.ID, reshared)
+	}
+
+	var txn types.Transaction
+	if err := json.Unmarshal(e.Payload, &txn); err != nil {
+		return fmt.Errorf("failed to decode transaction: %w", err)
+	}
+
+	var message string
+	switch e.Event {
+	case types.EventTransactionApproval, types.EventTransactionRejection:
+		// Progress is built when the event is dispatched and so reflects every decision committed by then.
+		progress, err := h.transactionProgress(txn)
+		if err != nil {
+			return fmt.Errorf("failed to build transaction progress: %w", err)
+		}
+		if err := h.hub.PublishOrganizationEvent(txn.OrganizationID, e.ID, progress); err != nil {
+			return err
+		}
+		return h.emitWebhook(e, txn)
+	case types.EventTransactionInitiated:
+		message = fmt.Sprintf("Transaction initiated by: %s", txn.Initiator)
+	case types.EventTransactionThresholdReached:
+		message = "Transaction confirmed by threshold"
+	case types.EventTransactionRejected:
+		message = "Transaction rejected, threshold can no longer be reached"
+	case types.EventTransactionCancelled:
+		message = fmt.Sprintf("Transaction cancelled by: %s", e.Actor)
+	case types.EventTransactionSigned:
+		message = "Transaction signed"
+	case types.EventTransactionExpired:
+		message = "Transaction expired"
+	case types.EventTransactionApplied:
+		message = "Membership proposal applied"
+	default:
+		return fmt.Errorf("unknown outbox event %q", e.Event)
+	}
+
+	err := h.hub.PublishOrganizationEvent(txn.OrganizationID, e.ID, types.TransactionNotification{
+		TransactionID:  txn.ID,
+		OrganizationID: txn.OrganizationID,
+		Initiator:      txn.Initiator,
+		Details:        txn.Details,
+		AccountID:      txn.AccountID,
+		DerivationPath: txn.DerivationPath,
+		Payload:        txn.Payload,
+		PayloadHash:    txn.PayloadHash,
+		Message:        message,
+	})
+	if err != nil {
+		return err
+	}
+	return h.emitWebhook(e, txn)
+}
+
+// pruneOutbox periodically drops dispatched and failed outbox events older than the retention window.
+func (h *Handler) pruneOutbox() {
+	ticker := time.NewTicker(h.outboxConf.PruneInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pruned, err := h.crudHandler.PruneOutboxEvents(h.outboxConf.Retention)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to prune outbox events")
+			continue
+		}
+		if pruned > 0 {
+			log.Info().Int64("count", pruned).Msg("Pruned outbox events")
+		}
+	}
+}
+
+// emitWebhook schedules the delivery of a transaction event to the
+// organization's webhooks. Events webhooks cannot subscribe to are skipped.
+func (h *Handler) emitWebhook(e types.OutboxEvent, txn types.Transaction) error {
+	if !webhooks.IsEvent(e.Event) {
+		return nil
+	}
+	payload, err := json.Marshal(types.WebhookEvent{
+		Event:          e.Event,
+		OrganizationID: txn.OrganizationID,
+		Transaction:    txn,
+		CreatedAt:      e.CreatedAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+	return h.crudHandler.EnqueueWebhookEvent(txn.OrganizationID, e.ID, e.Event, payload)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"mpc-backend/config"
+	"mpc-backend/types"
+	"testing"
+	"time"
+)
+
+func TestOutboxRetry(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		attempts int
+		status   types.OutboxStatus
+		next     time.Time
+	}{
+		{1, types.OutboxPending, now.Add(time.Minute)},
+		{2, types.OutboxPending, now.Add(2 * time.Minute)},
+		{3, types.OutboxFailed, now.Add(3 * time.Minute)},
+	}
+	for _, tt := range tests {
+		status, next := outboxRetry(tt.attempts, 3, time.Minute, now)
+		if status != tt.status || !next.Equal(tt.next) {
+			t.Errorf("outboxRetry(%d) = %s, %v, want %s, %v", tt.attempts, status, next, tt.status, tt.next)
+		}
+	}
+}
+
+// recordingStore remembers the organization events appended to it.
+type recordingStore struct {
+	fakeStore
+	appended []types.Frame
+	outbox   []int64
+}
+
+func (s *recordingStore) AppendOrganizationEvent(_ int, outboxID int64, frame types.Frame) (types.Frame, error) {
+	frame.Seq = int64(len(s.appended) + 1)
+	s.appended = append(s.appended, frame)
+	s.outbox = append(s.outbox, outboxID)
+	return frame, nil
+}
+
+func TestHandleOutboxEvent(t *testing.T) {
+	store := &recordingStore{}
+	hub, _ := newTestHub(t, config.HubConf{SendQueueSize: 1})
+	hub.store = store
+	h := &Handler{hub: hub}
+
+	payload, _ := json.Marshal(types.KeyReshared{OrganizationID: 4, KeyEpoch: 2})
+	if err := h.handleOutboxEvent(types.OutboxEvent{ID: 9, Event: types.EventKeyReshared, Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.appended) != 1 {
+		t.Fatalf("appended %d organization events, want 1", len(store.appended))
+	}
+	// The frame ID follows the outbox event, so a retried dispatch is recognised.
+	if f := store.appended[0]; f.ID != eventFrameID(9) || f.OrganizationID != 4 || f.Type != types.FrameKeyReshared || store.outbox[0] != 9 {
+		t.Fatalf("appended %+v of outbox event %d, want key reshare evt-9 of organization 4", f, store.outbox[0])
+	}
+
+	if err := h.handleOutboxEvent(types.OutboxEvent{ID: 10, Event: "unknown", Payload: []byte(`{}`)}); err == nil {
+		t.Error("an unknown event was dispatched")
+	}
+	if err := h.handleOutboxEvent(types.OutboxEvent{ID: 11, Event: types.EventKeyReshared, Payload: []byte(`[`)}); err == nil {
+		t.Error("an undecodable event was dispatched")
+	}
+}
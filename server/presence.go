@@ -0,0 +1,50 @@
+package server
+
+import (
+	"encoding/json"
+	"mpc-backend/types"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) GetPresenceHandler(w http.ResponseWriter, r *http.Request) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid organization id", http.StatusBadRequest)
+		return
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Organization not found")
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return
+	}
+
+	if !h.requireParticipant(w, org.ID, sessionAddress(r)) {
+		return
+	}
+
+	addresses := make([]string, 0, len(org.Participants))
+	for _, p := range org.Participants {
+		addresses = append(addresses, p.Address)
+	}
+
+	presence := types.OrganizationPresence{
+		OrganizationID: org.ID,
+		Threshold:      org.Threshold,
+		Participants:   h.hub.Presence(addresses),
+	}
+	for _, p := range presence.Participants {
+		if p.Online {
+			presence.Online++
+		}
+	}
+	presence.Reachable = presence.Online >= org.Threshold
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presence)
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mpc-backend/ceremony"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var errInvalidPayload = errors.New("invalid payload")
+
+// inboundHandler processes the payload of a client frame and returns the payload of the reply.
+type inboundHandler func(conn *Connection, payload json.RawMessage) (interface{}, error)
+
+// inboundHandlers is the registry of frame types clients may send.
+func (h *Handler) inboundHandlers() map[string]inboundHandler {
+	return map[string]inboundHandler{
+		types.FrameTransactionConfirm: h.handleConfirmFrame,
+		types.FrameTransactionReject:  h.handleRejectFrame,
+		types.FrameSubscribe:          h.handleSubscribeFrame,
+		types.FrameUnsubscribe:        h.handleUnsubscribeFrame,
+		types.FrameAck:                h.handleAckFrame,
+		types.FrameCeremonyMessage:    h.handleCeremonyMessageFrame,
+		types.FrameCeremonyEnvelope:   h.handleCeremonyEnvelopeFrame,
+		types.FrameCeremonyResult:     h.handleCeremonyResultFrame,
+	}
+}
+
+// ceremonyFrames are the frame types addressed to a ceremony session, which
+// only the instance running the session can handle.
+var ceremonyFrames = map[string]bool{
+	types.FrameCeremonyMessage:  true,
+	types.FrameCeremonyEnvelope: true,
+	types.FrameCeremonyResult:   true,
+}
+
+// handleInbound decodes a frame received on a connection, dispatches it to the
+// handler registered for its type and answers with a reply or error frame
+// correlated to the frame's ID.
+func (h *Handler) handleInbound(conn *Connection, data []byte) {
+	var frame types.Frame
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&frame); err != nil {
+		h.replyError(conn, frame.ID, types.ErrorMalformed, "frame is not a valid JSON envelope")
+		return
+	}
+	if frame.Type == "" || frame.ID == "" {
+		h.replyError(conn, frame.ID, types.ErrorMalformed, "frame needs a type and an id")
+		return
+	}
+	if frame.Version != types.ProtocolVersion {
+		h.replyError(conn, frame.ID, types.ErrorUnsupportedVersion, fmt.Sprintf("protocol version %d is not supported", frame.Version))
+		return
+	}
+
+	handle, ok := h.inbound[frame.Type]
+	if !ok {
+		h.replyError(conn, frame.ID, types.ErrorUnknownType, fmt.Sprintf("unknown frame type %q", frame.Type))
+		return
+	}
+
+	result, err := handle(conn, frame.Payload)
+	// The session may run on another instance, which then replies instead.
+	// Frames forwarded to this instance are answered here either way.
+	if errors.Is(err, ceremony.ErrSessionNotFound) && ceremonyFrames[frame.Type] && conn.instance == "" && h.hub.Clustered() {
+		if owner, ok := h.ceremonyOwner(frame.Payload); ok {
+			h.hub.Forward(conn, owner, data)
+			return
+		}
+	}
+	if err != nil {
+		code := types.ErrorRejected
+		if errors.Is(err, errInvalidPayload) {
+			code = types.ErrorInvalidPayload
+		}
+		h.replyError(conn, frame.ID, code, err.Error())
+		return
+	}
+
+	reply := types.Frame{Type: types.FrameReply, CorrelationID: frame.ID}
+	if result != nil {
+		if reply.Payload, err = json.Marshal(result); err != nil {
+			log.Error().Err(err).Str("type", frame.Type).Msg("Failed to encode reply")
+			return
+		}
+	}
+	h.hub.Send(conn, reply)
+}
+
+// ceremonyOwner returns the other instance running the ceremony session a
+// frame payload belongs to, as long as the session is still moving there.
+func (h *Handler) ceremonyOwner(payload json.RawMessage) (string, bool) {
+	var ref struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal(payload, &ref); err != nil || ref.SessionID == "" {
+		return "", false
+	}
+	s, err := h.crudHandler.GetCeremony(ref.SessionID)
+	if err != nil {
+		if !errors.Is(err, crud.ErrCeremonyNotFound) {
+			log.Error().Err(err).Str("session", ref.SessionID).Msg("Failed to look up ceremony session")
+		}
+		return "", false
+	}
+	// A running session moves at least once per round timeout.
+	return runningElsewhere(s, h.hub.ID(), 2*h.ceremonyConf.RoundTimeout, time.Now())
+}
+
+// runningElsewhere reports the instance a stored session runs on, unless it
+// is this one, unknown, or the session has finished or stopped moving.
+func runningElsewhere(s types.CeremonySession, self string, staleAfter time.Duration, now time.Time) (string, bool) {
+	if s.Status != types.CeremonyRunning || s.Instance == "" || s.Instance == self || now.Sub(s.UpdatedAt) >= staleAfter {
+		return "", false
+	}
+	return s.Instance, true
+}
+
+func (h *Handler) replyError(conn *Connection, correlationID, code, message string) {
+	payload, _ := json.Marshal(types.FrameErrorPayload{Code: code, Message: message})
+	h.hub.Send(conn, types.Frame{Type: types.FrameError, CorrelationID: correlationID, Payload: payload})
+}
+
+func (h *Handler) handleConfirmFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var req types.TransactionConfirmationRequest
+	if err := decodePayload(payload, &req); err != nil {
+		return nil, err
+	}
+	txn, err := h.confirmTransaction(conn.Address, req)
+	if err != nil {
+		return nil, err
+	}
+	return types.TransactionConfirmationResponse{
+		TransactionID: txn.ID,
+		Status:        txn.Status,
+		Confirmations: txn.Confirmations,
+		Threshold:     txn.Threshold,
+	}, nil
+}
+
+func (h *Handler) handleRejectFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var req types.TransactionRejectionRequest
+	if err := decodePayload(payload, &req); err != nil {
+		return nil, err
+	}
+	return h.rejectTransaction(conn.Address, req)
+}
+
+func (h *Handler) handleSubscribeFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var req types.SubscribeRequest
+	if err := decodePayload(payload, &req); err != nil {
+		return nil, err
+	}
+	member, err := h.crudHandler.IsParticipant(req.OrganizationID, conn.Address)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, crud.ErrNotParticipant
+	}
+	if req.LastSeq != nil {
+		h.hub.ResumeOrganizationRoom(req.OrganizationID, conn, *req.LastSeq)
+	} else {
+		h.hub.JoinOrganizationRoom(strconv.Itoa(req.OrganizationID), conn)
+	}
+	return types.Subscription{OrganizationID: req.OrganizationID, Subscribed: true}, nil
+}
+
+func (h *Handler) handleUnsubscribeFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var req types.SubscribeRequest
+	if err := decodePayload(payload, &req); err != nil {
+		return nil, err
+	}
+	h.hub.LeaveOrganizationRoom(strconv.Itoa(req.OrganizationID), conn)
+	return types.Subscription{OrganizationID: req.OrganizationID, Subscribed: false}, nil
+}
+
+func (h *Handler) handleAckFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var req types.AckRequest
+	if err := decodePayload(payload, &req); err != nil {
+		return nil, err
+	}
+	acked, err := h.crudHandler.AckMessages(conn.Address, req.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return types.AckResponse{Acknowledged: acked}, nil
+}
+
+func (h *Handler) handleCeremonyMessageFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var msg types.CeremonyMessage
+	if err := decodePayload(payload, &msg); err != nil {
+		return nil, err
+	}
+	return nil, h.ceremonies.HandleMessage(conn.Address, msg)
+}
+
+func (h *Handler) handleCeremonyEnvelopeFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var env types.Envelope
+	if err := decodePayload(payload, &env); err != nil {
+		return nil, err
+	}
+	return nil, h.ceremonies.HandleEnvelope(conn.Address, env)
+}
+
+func (h *Handler) handleCeremonyResultFrame(conn *Connection, payload json.RawMessage) (interface{}, error) {
+	var result types.CeremonyResult
+	if err := decodePayload(payload, &result); err != nil {
+		return nil, err
+	}
+	return nil, h.ceremonies.HandleResult(conn.Address, result)
+}
+
+func decodePayload(payload json.RawMessage, v interface{}) error {
+	if len(payload) == 0 {
+		return fmt.Errorf("%w: payload is missing", errInvalidPayload)
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidPayload, err)
+	}
+	return nil
+}
+
+// frameType names the outbound frame type of a message the server sends.
+func frameType(message interface{}) string {
+	switch message.(type) {
+	case types.TransactionNotification:
+		return types.FrameTransactionNotification
+	case types.TransactionProgress:
+		return types.FrameTransactionProgress
+	case types.CeremonyEvent:
+		return types.FrameCeremonyEvent
+	case types.CeremonyMessage:
+		return types.FrameCeremonyMessage
+	case types.Envelope:
+		return types.FrameCeremonyEnvelope
+	case types.PresenceEvent:
+		return types.FramePresence
+	case types.QueuedMessage:
+		return types.FrameQueued
+	case types.ResyncRequired:
+		return types.FrameResyncRequired
+	case types.InvitationMessage:
+		return types.FrameInvitation
+	case types.InvitationUpdate:
+		return types.FrameInvitationUpdate
+	case types.MembershipChange:
+		return types.FrameMembershipChanged
+	case types.KeyReshared:
+		return types.FrameKeyReshared
+	default:
+		return ""
+	}
+}
+
+// newFrame wraps a message in a versioned frame with a fresh ID. Frames are
+// kept as they are, apart from the version and a missing ID.
+func newFrame(message interface{}) (types.Frame, error) {
+	frame, ok := message.(types.Frame)
+	if !ok {
+		frame.Type = frameType(message)
+		if frame.Type == "" {
+			return frame, fmt.Errorf("no frame type registered for %T", message)
+		}
+		payload, err := json.Marshal(message)
+		if err != nil {
+			return frame, err
+		}
+		frame.Payload = payload
+	}
+
+	frame.Version = types.ProtocolVersion
+	if frame.ID == "" {
+		id, err := randomToken(9)
+		if err != nil {
+			return frame, err
+		}
+		frame.ID = id
+	}
+	return frame, nil
+}
+
+// encodeFrame wraps a message in a frame as newFrame does and encodes it.
+func encodeFrame(message interface{}) ([]byte, error) {
+	frame, err := newFrame(message)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(frame)
+}
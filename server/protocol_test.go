@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"mpc-backend/broker"
+	"mpc-backend/ceremony"
+	"mpc-backend/config"
+	"mpc-backend/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore keeps queued messages in memory and knows no organizations.
+type fakeStore struct {
+	mu        sync.Mutex
+	queued    map[string][]types.QueuedMessage
+	outboxIDs map[string][]int64
+}
+
+func (s *fakeStore) EnqueueMessage(address string, outboxID int64, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued == nil {
+		s.queued = make(map[string][]types.QueuedMessage)
+		s.outboxIDs = make(map[string][]int64)
+	}
+	s.queued[address] = append(s.queued[address], types.QueuedMessage{ID: int64(len(s.queued[address]) + 1), Payload: payload})
+	s.outboxIDs[address] = append(s.outboxIDs[address], outboxID)
+	return nil
+}
+
+func (s *fakeStore) GetQueuedMessages(address string) ([]types.QueuedMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued[address], nil
+}
+
+func (s *fakeStore) GetParticipantAddresses(int) ([]string, error) { return nil, nil }
+
+func (s *fakeStore) GetOrganizationsByAddress(string) ([]types.Organization, error) { return nil, nil }
+
+func (s *fakeStore) AppendOrganizationEvent(_ int, _ int64, frame types.Frame) (types.Frame, error) {
+	return frame, nil
+}
+
+func (s *fakeStore) GetOrganizationEvents(int, int64) ([]types.OrganizationEvent, error) {
+	return nil, nil
+}
+
+func newTestHub(t *testing.T, conf config.HubConf) (*Hub, *fakeStore) {
+	t.Helper()
+	b, err := broker.NewLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeStore{}
+	return NewHub(store, b, conf), store
+}
+
+// fakeTransport accepts every frame and records whether it was closed.
+type fakeTransport struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (f *fakeTransport) write([]byte, time.Duration) error { return nil }
+
+func (f *fakeTransport) ping(time.Duration) error { return nil }
+
+func (f *fakeTransport) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+
+// sent returns the frames queued on a connection without a running write pump.
+func sent(t *testing.T, conn *Connection) []types.Frame {
+	t.Helper()
+	var frames []types.Frame
+	for {
+		select {
+		case msg := <-conn.send:
+			var f types.Frame
+			if err := json.Unmarshal(msg, &f); err != nil {
+				t.Fatalf("sent %q: %v", msg, err)
+			}
+			frames = append(frames, f)
+		default:
+			return frames
+		}
+	}
+}
+
+func TestHandleInbound(t *testing.T) {
+	hub, _ := newTestHub(t, config.HubConf{SendQueueSize: 8})
+	h := &Handler{hub: hub, ceremonies: ceremony.NewManager(hub, time.Minute)}
+	h.inbound = h.inboundHandlers()
+
+	tests := []struct {
+		name  string
+		frame string
+		code  string
+	}{
+		{"not json", `{`, types.ErrorMalformed},
+		{"unknown field", `{"v":1,"type":"ack","id":"1","extra":true}`, types.ErrorMalformed},
+		{"no id", `{"v":1,"type":"ack"}`, types.ErrorMalformed},
+		{"old version", `{"v":0,"type":"ack","id":"1"}`, types.ErrorUnsupportedVersion},
+		{"unknown type", `{"v":1,"type":"nope","id":"1"}`, types.ErrorUnknownType},
+		{"missing payload", `{"v":1,"type":"ceremony.message","id":"1"}`, types.ErrorInvalidPayload},
+		// Without other instances nobody else can run the session.
+		{"unknown session", `{"v":1,"type":"ceremony.message","id":"1","payload":{"session_id":"s","round":1}}`, types.ErrorRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newConnection("c", "a", &fakeTransport{}, 8)
+			h.handleInbound(conn, []byte(tt.frame))
+
+			frames := sent(t, conn)
+			if len(frames) != 1 || frames[0].Type != types.FrameError {
+				t.Fatalf("sent %+v, want one error frame", frames)
+			}
+			var payload types.FrameErrorPayload
+			if err := json.Unmarshal(frames[0].Payload, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.Code != tt.code {
+				t.Fatalf("error %+v, want code %s", payload, tt.code)
+			}
+		})
+	}
+}
+
+func TestRunningElsewhere(t *testing.T) {
+	now := time.Now()
+	session := func(status types.CeremonyStatus, instance string, age time.Duration) types.CeremonySession {
+		return types.CeremonySession{Status: status, Instance: instance, UpdatedAt: now.Add(-age)}
+	}
+
+	tests := []struct {
+		name    string
+		session types.CeremonySession
+		want    string
+		ok      bool
+	}{
+		{"other instance", session(types.CeremonyRunning, "other", time.Second), "other", true},
+		{"this instance", session(types.CeremonyRunning, "self", time.Second), "", false},
+		{"unknown instance", session(types.CeremonyRunning, "", time.Second), "", false},
+		{"finished", session(types.CeremonyCompleted, "other", time.Second), "", false},
+		{"stopped moving", session(types.CeremonyRunning, "other", time.Minute), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := runningElsewhere(tt.session, "self", 30*time.Second, now)
+			if got != tt.want || ok != tt.ok {
+				t.Fatalf("runningElsewhere() = %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestDeliverForward(t *testing.T) {
+	hub, _ := newTestHub(t, config.HubConf{SendQueueSize: 8})
+	var forwarded []*Connection
+	hub.SetForwardHandler(func(conn *Connection, _ []byte) { forwarded = append(forwarded, conn) })
+
+	hub.deliver(broker.Event{Kind: broker.KindForward, Origin: "other", Instance: "third", Connection: "c1"})
+	hub.deliver(broker.Event{Kind: broker.KindForward, Origin: hub.ID(), Instance: hub.ID(), Connection: "c2"})
+	hub.deliver(broker.Event{Kind: broker.KindForward, Origin: "other", Instance: hub.ID(), Address: "a", Connection: "c3"})
+
+	if len(forwarded) != 1 {
+		t.Fatalf("handled %d forwards, want only the one addressed to this instance", len(forwarded))
+	}
+	if c := forwarded[0]; c.ID != "c3" || c.Address != "a" || c.instance != "other" {
+		t.Fatalf("forwarded connection %+v, want c3 of a on the origin", c)
+	}
+}
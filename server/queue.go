@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"mpc-backend/types"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) GetQueuedMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	messages, err := h.crudHandler.GetQueuedMessages(sessionAddress(r))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch queued messages")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(messages)
+}
+
+func (h *Handler) AckMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	var req types.AckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+
+	acked, err := h.crudHandler.AckMessages(sessionAddress(r), req.IDs)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to acknowledge messages")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(types.AckResponse{Acknowledged: acked})
+}
+
+// pruneQueuedMessages periodically drops queued messages older than the retention window.
+func (h *Handler) pruneQueuedMessages() {
+	ticker := time.NewTicker(h.queueConf.PruneInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pruned, err := h.crudHandler.PruneQueuedMessages(h.queueConf.Retention)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to prune queued messages")
+			continue
+		}
+		if pruned > 0 {
+			log.Info().Int64("count", pruned).Msg("Pruned queued messages")
+		}
+	}
+}
+
+// pruneOrganizationEvents periodically drops organization events older than the retention window.
+func (h *Handler) pruneOrganizationEvents() {
+	ticker := time.NewTicker(h.eventConf.PruneInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		pruned, err := h.crudHandler.PruneOrganizationEvents(h.eventConf.Retention)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to prune organization events")
+			continue
+		}
+		if pruned > 0 {
+			log.Info().Int64("count", pruned).Msg("Pruned organization events")
+		}
+	}
+}
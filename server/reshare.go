@@ -0,0 +1,207 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"mpc-backend/ceremony"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"mpc-backend/wallet"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errOrganizationNotReady = errors.New("too few participants have accepted their invitation to meet the threshold")
+	errNotEnoughHolders     = errors.New("too few share holders are still participants to reshare the key")
+)
+
+// StartReshareHandler lets the admin deal new shares of the organization's
+// group key, e.g. after its members changed.
+func (h *Handler) StartReshareHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := h.startReshare(org, sessionAddress(r))
+	if err != nil {
+		writeReshareError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// startReshare opens a session in which the share holders of the current epoch
+// that are still participants deal new shares of the same group key to every
+// current participant. Transactions of the organization are blocked until the
+// session completes or aborts.
+func (h *Handler) startReshare(org types.Organization, actor string) (types.CeremonySession, error) {
+	if org.GroupPublicKey == "" {
+		return types.CeremonySession{}, errNoGroupKey
+	}
+	if org.ReshareSession != "" {
+		return types.CeremonySession{}, crud.ErrReshareInProgress
+	}
+	if !org.Ready {
+		return types.CeremonySession{}, errOrganizationNotReady
+	}
+
+	member := make(map[string]bool, len(org.Participants))
+	participants := make([]string, 0, len(org.Participants))
+	for _, p := range org.Participants {
+		member[p.Address] = true
+		participants = append(participants, p.Address)
+	}
+	sort.Strings(participants)
+
+	// Removed participants keep their old shares but take no part in dealing new ones.
+	var holders []string
+	for _, addr := range org.ShareHolders {
+		if member[addr] {
+			holders = append(holders, addr)
+		}
+	}
+	if len(holders) < org.ShareThreshold {
+		return types.CeremonySession{}, errNotEnoughHolders
+	}
+
+	// The stored session holds the organization's transactions back, so it is
+	// only opened once the reshare is recorded.
+	session, err := h.ceremonies.Prepare(types.CeremonySession{
+		Kind:            ceremony.KindReshare,
+		OrganizationID:  org.ID,
+		Initiator:       actor,
+		Subject:         org.GroupPublicKey,
+		Participants:    participants,
+		OldParticipants: holders,
+		NewParticipants: participants,
+		Instance:        h.hub.ID(),
+	})
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+	if err := h.crudHandler.BeginReshare(session); err != nil {
+		return types.CeremonySession{}, err
+	}
+
+	started, err := h.ceremonies.Start(session, ceremony.Callbacks{
+		OnRound:    h.saveCeremony,
+		OnComplete: h.completeReshare,
+		OnAbort:    h.abortReshare,
+	})
+	if err != nil {
+		session.Status = types.CeremonyAborted
+		session.Reason = err.Error()
+		session.UpdatedAt = time.Now().UTC()
+		h.abortReshare(session)
+		return types.CeremonySession{}, err
+	}
+	return started, nil
+}
+
+// completeReshare starts the next key epoch once every participant reported
+// the unchanged group public key.
+func (h *Handler) completeReshare(s types.CeremonySession) {
+	if !sameKey(s.Result, s.Subject) {
+		s.Status = types.CeremonyAborted
+		s.Reason = "participants reported a different group key"
+		s.Blamed = s.Participants
+		s.Result = ""
+		h.abortReshare(s)
+		return
+	}
+
+	reshared, err := h.crudHandler.CompleteReshare(s)
+	if err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to store key reshare")
+		return
+	}
+	h.wakeOutbox()
+	log.Info().Int("organization", s.OrganizationID).Str("session", s.ID).Int("epoch", reshared.KeyEpoch).Msg("Key reshare completed")
+}
+
+// abortReshare keeps the shares of the current epoch in use and unblocks the
+// organization's transactions.
+func (h *Handler) abortReshare(s types.CeremonySession) {
+	if err := h.crudHandler.AbortReshare(s); err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to store aborted key reshare")
+		return
+	}
+	log.Warn().Str("session", s.ID).Int("organization", s.OrganizationID).Strs("blamed", s.Blamed).Str("reason", s.Reason).Msg("Key reshare aborted")
+}
+
+// reshareKeys periodically starts the reshares that are due because the
+// participants changed or the shares are older than the refresh interval, and
+// releases ceremonies left behind by instances that went away.
+func (h *Handler) reshareKeys() {
+	ticker := time.NewTicker(h.ceremonyConf.ReshareCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// A running session moves at least once per round timeout.
+		h.releaseStaleCeremonies(2 * h.ceremonyConf.RoundTimeout)
+
+		due, err := h.crudHandler.GetDueReshares(h.ceremonyConf.RefreshInterval)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to fetch due key reshares")
+			continue
+		}
+		for _, orgID := range due {
+			h.reshareIfOnline(orgID)
+		}
+	}
+}
+
+// reshareIfOnline starts a reshare of the organization when every participant
+// is connected, as the session cannot complete without all of them.
+func (h *Handler) reshareIfOnline(orgID int) {
+	org, err := h.crudHandler.GetOrganizationByID(orgID)
+	if err != nil {
+		log.Error().Err(err).Int("organization", orgID).Msg("Failed to fetch organization for key reshare")
+		return
+	}
+	for _, p := range org.Participants {
+		if !h.hub.IsOnline(p.Address) {
+			return
+		}
+	}
+
+	session, err := h.startReshare(org, "")
+	if err != nil {
+		log.Debug().Err(err).Int("organization", orgID).Msg("Key reshare not started")
+		return
+	}
+	log.Info().Int("organization", orgID).Str("session", session.ID).Msg("Key reshare started")
+}
+
+// sameKey reports whether two hex encoded public keys are the same point,
+// whether compressed or not.
+func sameKey(a, b string) bool {
+	pa, err := wallet.ParsePublicKey(a)
+	if err != nil {
+		return false
+	}
+	pb, err := wallet.ParsePublicKey(b)
+	if err != nil {
+		return false
+	}
+	return pa.IsEqual(pb)
+}
+
+func writeReshareError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoGroupKey), errors.Is(err, errOrganizationNotReady), errors.Is(err, errNotEnoughHolders),
+		errors.Is(err, crud.ErrReshareInProgress), errors.Is(err, crud.ErrSigningInProgress), errors.Is(err, ceremony.ErrTooFewMembers):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error().Err(err).Msg("Key reshare error")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+	}
+}
@@ -2,9 +2,11 @@ package server
 
 import (
 	"fmt"
+	"mpc-backend/broker"
 	"mpc-backend/config"
 	crud "mpc-backend/core"
 	"mpc-backend/db"
+	"mpc-backend/wallet"
 
 	"github.com/rs/zerolog/log"
 )
@@ -14,6 +16,10 @@ type Server struct {
 }
 
 func NewServer(conf config.Configuration) error {
+	if _, err := wallet.BitcoinHRP(conf.KeyConf.BitcoinNetwork); err != nil {
+		return fmt.Errorf("invalid key configuration: %w", err)
+	}
+
 	masterDb, err := db.NewMasterDb(conf.DbConfig)
 	if err != nil {
 		return fmt.Errorf("could not connect to db: %w", err)
@@ -21,7 +27,21 @@ func NewServer(conf config.Configuration) error {
 
 	crudHandler := crud.NewCRUD(masterDb)
 
-	handler := NewHandler(conf, crudHandler)
+	var b broker.Broker
+	switch conf.BrokerConf.Backend {
+	case "local", "":
+		b, err = broker.NewLocal()
+	case "postgres":
+		b, err = broker.NewPostgres(masterDb, conf.BrokerConf)
+	default:
+		err = fmt.Errorf("unknown broker backend %q", conf.BrokerConf.Backend)
+	}
+	if err != nil {
+		return fmt.Errorf("could not start hub broker: %w", err)
+	}
+	defer b.Close()
+
+	handler := NewHandler(conf, crudHandler, b)
 
 	if err := handler.Run(); err != nil {
 		log.Fatal().Err(err)
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mpc-backend/auth"
+	"mpc-backend/ceremony"
+	"mpc-backend/types"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+var (
+	errNoGroupKey         = errors.New("organization has no group key yet")
+	errNotEnoughSigners   = errors.New("not enough approvers left to form a signer set")
+	errNotReadyForSigning = errors.New("transaction is not approved")
+)
+
+// signingRetry tracks the signers blamed across the signing attempts of a transaction.
+type signingRetry struct {
+	attempts int
+	excluded map[string]bool
+}
+
+func (h *Handler) StartSigningHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid transaction id", http.StatusBadRequest)
+		return
+	}
+
+	txn, err := h.crudHandler.GetTransaction(id)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	address := sessionAddress(r)
+	if !h.requireParticipant(w, txn.OrganizationID, address) {
+		return
+	}
+
+	// A manual retry gives previously blamed signers another chance.
+	h.forgetSigningRetry(txn.ID)
+
+	session, err := h.startSigning(txn.ID, address)
+	if err != nil {
+		if errors.Is(err, errNoGroupKey) || errors.Is(err, errNotEnoughSigners) || errors.Is(err, errNotReadyForSigning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		writeTransactionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// startSigning picks a signer set from the approvers of an approved transaction
+// and opens a signing session between them. The set is as large as the
+// threshold the current shares were dealt for, and only approvers that hold
+// such a share and are still participants can sign. Connected approvers are
+// preferred, and signers blamed in earlier attempts are skipped.
+func (h *Handler) startSigning(txID int, actor string) (types.CeremonySession, error) {
+	txn, err := h.crudHandler.GetTransaction(txID)
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+	if txn.Status != types.TransactionApproved {
+		return types.CeremonySession{}, errNotReadyForSigning
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(txn.OrganizationID)
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+	if org.GroupPublicKey == "" {
+		return types.CeremonySession{}, errNoGroupKey
+	}
+
+	approvals, err := h.crudHandler.GetTransactionApprovals(txn.ID)
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+
+	var excluded map[string]bool
+	h.signingMu.Lock()
+	if retry := h.signingRetries[txn.ID]; retry != nil {
+		excluded = retry.excluded
+	}
+	signers, err := signerSet(org, approvals, excluded, h.hub.IsOnline)
+	h.signingMu.Unlock()
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+
+	txn, err = h.crudHandler.TransitionTransaction(txn.ID, types.TransactionSigning, actor)
+	if err != nil {
+		return types.CeremonySession{}, err
+	}
+
+	session, err := h.ceremonies.Start(types.CeremonySession{
+		Kind:           ceremony.KindSigning,
+		OrganizationID: txn.OrganizationID,
+		TransactionID:  txn.ID,
+		Initiator:      actor,
+		Subject:        txn.PayloadHash,
+		Participants:   signers,
+		Instance:       h.hub.ID(),
+	}, ceremony.Callbacks{
+		OnRound:    h.saveCeremony,
+		OnComplete: h.completeSigning,
+		OnAbort:    h.abortSigning,
+	})
+	if err != nil {
+		if _, rollbackErr := h.crudHandler.TransitionTransaction(txn.ID, types.TransactionApproved, ""); rollbackErr != nil {
+			log.Error().Err(rollbackErr).Int("transaction", txn.ID).Msg("Failed to reset transaction after signing start failure")
+		}
+		return types.CeremonySession{}, err
+	}
+	h.saveCeremony(session)
+
+	h.notifySigning(txn, fmt.Sprintf("Signing session %s started", session.ID))
+	return session, nil
+}
+
+// signerSet picks the first threshold approvers of a transaction that hold a
+// share of the current epoch, are still participants and were not excluded,
+// connected ones first.
+func signerSet(org types.Organization, approvals []types.Approval, excluded map[string]bool, online func(string) bool) ([]string, error) {
+	// Removed participants keep their old shares but must not sign with them.
+	member := make(map[string]bool, len(org.Participants))
+	for _, p := range org.Participants {
+		member[p.Address] = true
+	}
+	holder := make(map[string]bool, len(org.ShareHolders))
+	for _, addr := range org.ShareHolders {
+		if member[addr] {
+			holder[addr] = true
+		}
+	}
+
+	var connected, offline []string
+	for _, a := range approvals {
+		if !holder[a.Address] || excluded[a.Address] {
+			continue
+		}
+		if online(a.Address) {
+			connected = append(connected, a.Address)
+		} else {
+			offline = append(offline, a.Address)
+		}
+	}
+	candidates := append(connected, offline...)
+	if org.ShareThreshold < 1 || len(candidates) < org.ShareThreshold {
+		return nil, errNotEnoughSigners
+	}
+	return candidates[:org.ShareThreshold], nil
+}
+
+// completeSigning checks the signature reported by the signers against the key
+// of the transaction and stores it on the transaction.
+func (h *Handler) completeSigning(s types.CeremonySession) {
+	publicKey, err := h.signingKey(s.OrganizationID, s.TransactionID)
+	if err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to fetch signing key for signature check")
+		return
+	}
+
+	digest, err := auth.DecodeHex(s.Subject)
+	if err == nil {
+		var signature []byte
+		if signature, err = auth.DecodeHex(s.Result); err == nil {
+			err = auth.VerifyDigest(publicKey, digest, signature)
+		}
+	}
+	if err != nil {
+		s.Status = types.CeremonyAborted
+		s.Reason = fmt.Sprintf("signature does not verify: %v", err)
+		s.Blamed = s.Participants
+		s.Result = ""
+		h.abortSigning(s)
+		return
+	}
+
+	txn, err := h.crudHandler.CompleteSigning(s)
+	if err != nil {
+		log.Error().Err(err).Str("session", s.ID).Msg("Failed to store signature")
+		return
+	}
+
+	h.forgetSigningRetry(txn.ID)
+	h.wakeOutbox()
+}
+
+// signingKey returns the public key a transaction is signed with: the child
+// key of its account, or the organization's group key.
+func (h *Handler) signingKey(orgID, txID int) (string, error) {
+	txn, err := h.crudHandler.GetTransaction(txID)
+	if err != nil {
+		return "", err
+	}
+	if txn.AccountID != nil {
+		account, err := h.crudHandler.GetKeyAccount(orgID, *txn.AccountID)
+		if err != nil {
+			return "", err
+		}
+		return account.PublicKey, nil
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(orgID)
+	if err != nil {
+		return "", err
+	}
+	return org.GroupPublicKey, nil
+}
+
+// abortSigning blames the signers that stalled the session and retries with a
+// different signer set while attempts remain.
+func (h *Handler) abortSigning(s types.CeremonySession) {
+	h.saveCeremony(s)
+
+	attempts := h.recordSigningAbort(s)
+	log.Warn().Str("session", s.ID).Int("transaction", s.TransactionID).Strs("blamed", s.Blamed).Str("reason", s.Reason).Msg("Signing session aborted")
+
+	txn, err := h.crudHandler.TransitionTransaction(s.TransactionID, types.TransactionApproved, "")
+	if err != nil {
+		log.Error().Err(err).Int("transaction", s.TransactionID).Msg("Failed to reset transaction after aborted signing")
+		return
+	}
+
+	if attempts < h.ceremonyConf.SigningAttempts {
+		if _, err = h.startSigning(txn.ID, ""); err == nil {
+			return
+		}
+	} else {
+		err = fmt.Errorf("gave up after %d attempts", attempts)
+	}
+
+	// The transaction waits for a manual retry, which starts from a clean slate.
+	h.forgetSigningRetry(txn.ID)
+	h.notifySigning(txn, fmt.Sprintf("Signing stalled: %v", err))
+}
+
+// releaseStaleSignings hands transactions whose signing session was lost,
+// e.g. with the instance running it, back to their approvers.
+func (h *Handler) releaseStaleSignings(staleAfter time.Duration) {
+	released, err := h.crudHandler.ReleaseStaleSignings(staleAfter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to release stale signing sessions")
+		return
+	}
+	for _, txn := range released {
+		log.Warn().Int("transaction", txn.ID).Msg("Released stale signing session")
+		h.forgetSigningRetry(txn.ID)
+		h.notifySigning(txn, "Signing stalled: session was lost")
+	}
+	if len(released) > 0 {
+		h.wakeOutbox()
+	}
+}
+
+// recordSigningAbort counts an aborted signing attempt of a transaction and
+// excludes its blamed signers from the next ones. It returns the attempts made.
+func (h *Handler) recordSigningAbort(s types.CeremonySession) int {
+	h.signingMu.Lock()
+	defer h.signingMu.Unlock()
+
+	retry, ok := h.signingRetries[s.TransactionID]
+	if !ok {
+		retry = &signingRetry{excluded: make(map[string]bool)}
+		h.signingRetries[s.TransactionID] = retry
+	}
+	retry.attempts++
+	for _, addr := range s.Blamed {
+		retry.excluded[addr] = true
+	}
+	return retry.attempts
+}
+
+func (h *Handler) forgetSigningRetry(txID int) {
+	h.signingMu.Lock()
+	delete(h.signingRetries, txID)
+	h.signingMu.Unlock()
+}
+
+// notifySigning tells the organization's room about the signing of a transaction.
+func (h *Handler) notifySigning(txn types.Transaction, message string) {
+	h.hub.BroadcastOrganization(fmt.Sprintf("%d", txn.OrganizationID), types.TransactionNotification{
+		TransactionID:  txn.ID,
+		OrganizationID: txn.OrganizationID,
+		Initiator:      txn.Initiator,
+		Details:        txn.Details,
+		AccountID:      txn.AccountID,
+		DerivationPath: txn.DerivationPath,
+		Payload:        txn.Payload,
+		PayloadHash:    txn.PayloadHash,
+		Message:        message,
+	})
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"mpc-backend/types"
+	"reflect"
+	"testing"
+)
+
+func TestSignerSet(t *testing.T) {
+	org := types.Organization{
+		Participants:   []types.Participant{{Address: "a"}, {Address: "b"}, {Address: "c"}, {Address: "d"}},
+		ShareHolders:   []string{"a", "b", "c", "e"},
+		ShareThreshold: 2,
+	}
+	approvals := func(addrs ...string) []types.Approval {
+		var a []types.Approval
+		for _, addr := range addrs {
+			a = append(a, types.Approval{Address: addr})
+		}
+		return a
+	}
+	online := func(addrs ...string) func(string) bool {
+		return func(addr string) bool {
+			for _, a := range addrs {
+				if a == addr {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	tests := []struct {
+		name      string
+		approvals []types.Approval
+		excluded  map[string]bool
+		online    func(string) bool
+		want      []string
+		err       error
+	}{
+		{"approval order", approvals("a", "b", "c"), nil, online(), []string{"a", "b"}, nil},
+		{"connected first", approvals("a", "b", "c"), nil, online("c"), []string{"c", "a"}, nil},
+		{"excluded skipped", approvals("a", "b", "c"), map[string]bool{"a": true}, online("a"), []string{"b", "c"}, nil},
+		{"not a holder", approvals("d", "a", "b"), nil, online("d"), []string{"a", "b"}, nil},
+		{"removed holder", approvals("e", "a"), nil, online(), nil, errNotEnoughSigners},
+		{"too few after exclusion", approvals("a", "b"), map[string]bool{"b": true}, online(), nil, errNotEnoughSigners},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := signerSet(org, tt.approvals, tt.excluded, tt.online)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("signers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignerSetWithoutShareThreshold(t *testing.T) {
+	org := types.Organization{Participants: []types.Participant{{Address: "a"}}, ShareHolders: []string{"a"}}
+	if _, err := signerSet(org, []types.Approval{{Address: "a"}}, nil, func(string) bool { return true }); !errors.Is(err, errNotEnoughSigners) {
+		t.Fatalf("err = %v, want %v", err, errNotEnoughSigners)
+	}
+}
+
+func TestRecordSigningAbort(t *testing.T) {
+	h := &Handler{signingRetries: make(map[int]*signingRetry)}
+
+	if n := h.recordSigningAbort(types.CeremonySession{TransactionID: 1, Blamed: []string{"a"}}); n != 1 {
+		t.Fatalf("attempts = %d, want 1", n)
+	}
+	if n := h.recordSigningAbort(types.CeremonySession{TransactionID: 1, Blamed: []string{"b"}}); n != 2 {
+		t.Fatalf("attempts = %d, want 2", n)
+	}
+	if n := h.recordSigningAbort(types.CeremonySession{TransactionID: 2}); n != 1 {
+		t.Fatalf("attempts of another transaction = %d, want 1", n)
+	}
+	if got := h.signingRetries[1].excluded; !got["a"] || !got["b"] {
+		t.Fatalf("excluded = %v, want a and b", got)
+	}
+
+	h.forgetSigningRetry(1)
+	if _, ok := h.signingRetries[1]; ok {
+		t.Fatal("retry kept after it was forgotten")
+	}
+	if n := h.recordSigningAbort(types.CeremonySession{TransactionID: 1}); n != 1 {
+		t.Fatalf("attempts after forgetting = %d, want 1", n)
+	}
+}
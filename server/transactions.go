@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"mpc-backend/auth"
+	"mpc-backend/payload"
+	"mpc-backend/types"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var errInvalidSignatureEncoding = errors.New("invalid signature encoding")
+
+// confirmTransaction verifies and records an approval, which the outbox announces to the organization.
+// Reaching the threshold opens a signing session between the approvers, unless
+// the transaction is a membership proposal, which is applied right away. It backs
+// both the HTTP endpoint and the transaction.confirm frame.
+func (h *Handler) confirmTransaction(address string, req types.TransactionConfirmationRequest) (types.Transaction, error) {
+	pending, err := h.crudHandler.GetTransaction(req.TransactionID)
+	if err != nil {
+		return pending, err
+	}
+
+	// The approval must be signed over the organization, the transaction and the
+	// stored payload hash, so it cannot be reused for any other transaction.
+	if req.Scheme == "" {
+		req.Scheme = auth.SchemeEIP191
+	}
+	if err := h.verifyDecision(pending, address, req.Signature, req.Scheme, payload.DecisionApprove); err != nil {
+		return pending, err
+	}
+
+	txn, err := h.crudHandler.ConfirmTransaction(pending.ID, address, req.Signature, req.Scheme, time.Now().Add(h.inviteConf.TTL))
+	if err != nil {
+		return txn, err
+	}
+
+	// Progress and, once the threshold is reached, the final notification are sent from the outbox.
+	h.wakeOutbox()
+
+	if txn.Status == types.TransactionApproved {
+		// Reaching the threshold opens a signing session between the approvers.
+		if _, err := h.startSigning(txn.ID, address); err != nil {
+			log.Warn().Err(err).Int("transaction", txn.ID).Msg("Could not start signing session")
+		}
+	}
+
+	return txn, nil
+}
+
+// rejectTransaction verifies and records a rejection, which the outbox announces to the organization.
+// It backs both the HTTP endpoint and the transaction.reject frame.
+func (h *Handler) rejectTransaction(address string, req types.TransactionRejectionRequest) (types.Transaction, error) {
+	pending, err := h.crudHandler.GetTransaction(req.TransactionID)
+	if err != nil {
+		return pending, err
+	}
+
+	if req.Scheme == "" {
+		req.Scheme = auth.SchemeEIP191
+	}
+	if err := h.verifyDecision(pending, address, req.Signature, req.Scheme, payload.DecisionReject); err != nil {
+		return pending, err
+	}
+
+	txn, err := h.crudHandler.RejectTransaction(pending.ID, address, req.Signature, req.Scheme)
+	if err != nil {
+		return txn, err
+	}
+
+	h.wakeOutbox()
+	return txn, nil
+}
+
+// verifyDecision checks a participant's signature over the decision message of
+// a transaction.
+func (h *Handler) verifyDecision(txn types.Transaction, address, signatureHex, scheme, decision string) error {
+	signature, err := auth.DecodeHex(signatureHex)
+	if err != nil {
+		return errInvalidSignatureEncoding
+	}
+	hash, err := auth.DecodeHex(txn.PayloadHash)
+	if err != nil {
+		return fmt.Errorf("stored payload hash of transaction %d is malformed: %w", txn.ID, err)
+	}
+	return h.verifiers.Verify(scheme, address, payload.DecisionMessage(decision, txn.OrganizationID, txn.ID, hash), signature)
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	crud "mpc-backend/core"
+	"mpc-backend/types"
+	"mpc-backend/webhooks"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// maxDeliveryPage caps the number of deliveries returned by the delivery log.
+const maxDeliveryPage = 200
+
+func (h *Handler) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req types.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhook(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if req.Secret == "" {
+		secret, err := randomToken(32)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to generate webhook secret")
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			return
+		}
+		req.Secret = secret
+	}
+
+	webhook, err := h.crudHandler.CreateWebhook(types.Webhook{
+		OrganizationID: org.ID,
+		URL:            req.URL,
+		Events:         normalizeEvents(req.Events),
+		Secret:         req.Secret,
+		Active:         req.Active == nil || *req.Active,
+		CreatedBy:      sessionAddress(r),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create webhook")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *Handler) GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	hooks, err := h.crudHandler.GetWebhooks(org.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch webhooks")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+func (h *Handler) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	webhookID, _ := strconv.Atoi(mux.Vars(r)["webhook"])
+
+	var req types.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhook(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Secret != "" {
+		http.Error(w, "The secret of a webhook cannot be changed", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.crudHandler.UpdateWebhook(org.ID, webhookID, req.URL, normalizeEvents(req.Events), req.Active == nil || *req.Active)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *Handler) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	webhookID, _ := strconv.Atoi(mux.Vars(r)["webhook"])
+
+	if err := h.crudHandler.DeleteWebhook(org.ID, webhookID); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	webhookID, _ := strconv.Atoi(mux.Vars(r)["webhook"])
+	if _, err := h.crudHandler.GetWebhook(org.ID, webhookID); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	status := types.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", types.WebhookDeliveryPending, types.WebhookDeliveryDelivered, types.WebhookDeliveryFailed:
+	default:
+		http.Error(w, "Invalid delivery status", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveryPage {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxDeliveryPage), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.crudHandler.GetWebhookDeliveries(webhookID, status, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fetch webhook deliveries")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (h *Handler) RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	org, ok := h.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	webhookID, _ := strconv.Atoi(mux.Vars(r)["webhook"])
+	deliveryID, _ := strconv.ParseInt(mux.Vars(r)["delivery"], 10, 64)
+	if _, err := h.crudHandler.GetWebhook(org.ID, webhookID); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	delivery, err := h.crudHandler.RedeliverWebhookDelivery(webhookID, deliveryID)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+// requireAdmin loads the organization of the request and writes an error unless
+// the caller is its admin.
+func (h *Handler) requireAdmin(w http.ResponseWriter, r *http.Request) (types.Organization, bool) {
+	orgID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid organization id", http.StatusBadRequest)
+		return types.Organization{}, false
+	}
+
+	org, err := h.crudHandler.GetOrganizationByID(orgID)
+	if err != nil {
+		log.Error().Err(err).Msg("Organization not found")
+		http.Error(w, "Organization not found", http.StatusNotFound)
+		return org, false
+	}
+	if org.Admin != sessionAddress(r) {
+		http.Error(w, "Only the organization admin can do this", http.StatusForbidden)
+		return org, false
+	}
+	return org, true
+}
+
+func validateWebhook(req types.WebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	for _, e := range req.Events {
+		if !webhooks.IsEvent(e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	return nil
+}
+
+// normalizeEvents drops duplicate events; nil becomes an empty filter, which matches every event.
+func normalizeEvents(events []string) []string {
+	seen := make(map[string]bool, len(events))
+	normalized := []string{}
+	for _, e := range events {
+		if !seen[e] {
+			seen[e] = true
+			normalized = append(normalized, e)
+		}
+	}
+	return normalized
+}
+
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, crud.ErrWebhookNotFound), errors.Is(err, crud.ErrWebhookDeliveryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Error().Err(err).Msg("Webhook error")
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+	}
+}
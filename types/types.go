@@ -1,5 +1,10 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Participant struct {
 	Address string `json:"address"`
 }
@@ -11,34 +16,659 @@ type CreateOrganizationRequest struct {
 }
 
 type Organization struct {
-	ID           int           `json:"id"`
-	Name         string        `json:"name"`
-	Threshold    int           `json:"threshold"`
-	Participants []Participant `json:"participants"`
+	ID             int           `json:"id"`
+	Name           string        `json:"name"`
+	Threshold      int           `json:"threshold"`
+	Admin          string        `json:"admin,omitempty"`
+	GroupPublicKey string        `json:"group_public_key,omitempty"`
+	Participants   []Participant `json:"participants"`
+	// Ready is set once enough invitees have accepted to meet the threshold, so key generation can start.
+	Ready bool `json:"ready"`
+	// KeyEpoch counts the generations of key shares: 1 after key generation, raised by every reshare.
+	KeyEpoch int `json:"key_epoch"`
+	// ShareHolders are the participants holding shares of the current epoch, ShareThreshold
+	// how many of them are needed to use the key.
+	ShareHolders   []string `json:"share_holders,omitempty"`
+	ShareThreshold int      `json:"share_threshold,omitempty"`
+	// ReshareRequired is set when the participants changed since the shares were dealt.
+	ReshareRequired bool `json:"reshare_required"`
+	// ReshareSession is the running reshare session; transactions are blocked until it finishes.
+	ReshareSession string `json:"reshare_session,omitempty"`
+	// Keys are the group public keys the organization controls, with their addresses.
+	Keys []OrganizationKey `json:"keys,omitempty"`
+}
+
+// Curve and signature protocol of the group keys produced by key generation.
+const (
+	CurveSecp256k1 = "secp256k1"
+	ProtocolECDSA  = "ecdsa"
+)
+
+// OrganizationKey is a group public key of an organization. Epoch is the key
+// epoch of the shares currently used with it.
+type OrganizationKey struct {
+	ID             int            `json:"id"`
+	OrganizationID int            `json:"organization_id"`
+	PublicKey      string         `json:"public_key"`
+	Curve          string         `json:"curve"`
+	Protocol       string         `json:"protocol"`
+	Epoch          int            `json:"epoch"`
+	CeremonyID     string         `json:"ceremony_id,omitempty"`
+	Addresses      ChainAddresses `json:"addresses"`
+	Accounts       []KeyAccount   `json:"accounts"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// KeyAccount is a child of an organization key at a non-hardened BIP32 path,
+// letting one organization manage several accounts. Transactions initiated for
+// an account are signed with its child key.
+type KeyAccount struct {
+	ID        int            `json:"id"`
+	KeyID     int            `json:"key_id"`
+	Path      string         `json:"path"`
+	Label     string         `json:"label"`
+	PublicKey string         `json:"public_key"`
+	Addresses ChainAddresses `json:"addresses"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ChainAddresses are the addresses of a public key on the supported chains:
+// an EIP-55 checksummed EVM address and a bech32 P2WPKH Bitcoin address.
+type ChainAddresses struct {
+	EVM     string `json:"evm"`
+	Bitcoin string `json:"bitcoin"`
+}
+
+// CreateKeyAccountRequest derives a new account of an organization key.
+type CreateKeyAccountRequest struct {
+	Path  string `json:"path"`
+	Label string `json:"label"`
+}
+
+// InvitationStatus is the state of an invitation to join an organization.
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "pending"
+	InvitationAccepted InvitationStatus = "accepted"
+	InvitationDeclined InvitationStatus = "declined"
+	InvitationExpired  InvitationStatus = "expired"
+)
+
+// Invitation asks an address to join an organization. The address only becomes
+// a participant once it accepts.
+type Invitation struct {
+	ID               int              `json:"id"`
+	OrganizationID   int              `json:"organization_id"`
+	OrganizationName string           `json:"organization_name"`
+	Address          string           `json:"address"`
+	Status           InvitationStatus `json:"status"`
+	InvitedBy        string           `json:"invited_by"`
+	CreatedAt        time.Time        `json:"created_at"`
+	ExpiresAt        time.Time        `json:"expires_at"`
+	RespondedAt      *time.Time       `json:"responded_at,omitempty"`
 }
 
+// InvitationMessage tells an address it has been invited to an organization.
 type InvitationMessage struct {
-	OrganizationID   int    `json:"organization_id"`
-	OrganizationName string `json:"organization_name"`
-	Message          string `json:"message"`
+	InvitationID     int       `json:"invitation_id"`
+	OrganizationID   int       `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	Message          string    `json:"message"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// InvitationUpdate tells an organization that an invitation was accepted,
+// declined or expired, and whether enough members have accepted to start key generation.
+type InvitationUpdate struct {
+	InvitationID   int              `json:"invitation_id"`
+	OrganizationID int              `json:"organization_id"`
+	Address        string           `json:"address"`
+	Status         InvitationStatus `json:"status"`
+	Accepted       int              `json:"accepted"`
+	Threshold      int              `json:"threshold"`
+	Ready          bool             `json:"ready"`
 }
 
 // TransactionRequest is the payload when initiating a transaction.
+// The initiator is the authenticated caller. AccountID selects a derived
+// account of the organization key to sign with instead of the key itself.
 type TransactionRequest struct {
-	OrganizationName string `json:"organization_name"`
-	Initiator        string `json:"initiator"`
+	OrganizationName string             `json:"organization_name"`
+	Details          string             `json:"details"`
+	AccountID        int                `json:"account_id,omitempty"`
+	Payload          TransactionPayload `json:"payload"`
+}
+
+// TransactionPayload is the typed content of a transaction. Type selects which
+// of the payload fields is set.
+type TransactionPayload struct {
+	Type       string             `json:"type"`
+	EVM        *EVMPayload        `json:"evm,omitempty"`
+	Membership *MembershipPayload `json:"membership,omitempty"`
+}
+
+// MembershipPayload proposes to invite and remove participants of an
+// organization and to change its threshold. It is approved like any other
+// transaction and applied instead of signed. A zero Threshold keeps the current one.
+type MembershipPayload struct {
+	OrganizationID int      `json:"organization_id"`
+	Add            []string `json:"add,omitempty"`
+	Remove         []string `json:"remove,omitempty"`
+	Threshold      int      `json:"threshold,omitempty"`
+}
+
+// MembershipProposalRequest is the payload when proposing a membership or threshold change.
+type MembershipProposalRequest struct {
+	Add       []string `json:"add"`
+	Remove    []string `json:"remove"`
+	Threshold int      `json:"threshold"`
+	Details   string   `json:"details"`
+}
+
+// MembershipChange tells an organization that an approved proposal changed its
+// members or threshold. Existing key shares no longer match the new signer set,
+// so a group key has to be reshared.
+type MembershipChange struct {
+	OrganizationID  int      `json:"organization_id"`
+	ProposalID      int      `json:"proposal_id"`
+	Added           []string `json:"added"`
+	Removed         []string `json:"removed"`
+	Threshold       int      `json:"threshold"`
+	Participants    []string `json:"participants"`
+	ReshareRequired bool     `json:"reshare_required"`
+	Message         string   `json:"message"`
+}
+
+// KeyReshared tells an organization that its key shares were dealt anew to
+// its participants. The group public key is unchanged, shares of earlier
+// epochs are no longer used.
+type KeyReshared struct {
+	OrganizationID int      `json:"organization_id"`
+	SessionID      string   `json:"session_id"`
+	KeyEpoch       int      `json:"key_epoch"`
+	Participants   []string `json:"participants"`
+	Threshold      int      `json:"threshold"`
+	Message        string   `json:"message"`
+}
+
+// EVMPayload is an unsigned EVM transaction. Quantities are decimal strings,
+// byte fields are 0x prefixed hex. Setting MaxFeePerGas makes it an EIP-1559
+// transaction, otherwise GasPrice is used.
+type EVMPayload struct {
+	ChainID              string `json:"chain_id"`
+	Nonce                uint64 `json:"nonce"`
+	To                   string `json:"to"`
+	Value                string `json:"value"`
+	Data                 string `json:"data"`
+	Gas                  uint64 `json:"gas"`
+	GasPrice             string `json:"gas_price,omitempty"`
+	MaxFeePerGas         string `json:"max_fee_per_gas,omitempty"`
+	MaxPriorityFeePerGas string `json:"max_priority_fee_per_gas,omitempty"`
 }
 
 // TransactionNotification is sent to all members of an organization.
 type TransactionNotification struct {
-	OrganizationID int    `json:"organization_id"`
-	Initiator      string `json:"initiator"`
-	Details        string `json:"details"`
-	Message        string `json:"message"`
+	TransactionID  int                `json:"transaction_id"`
+	OrganizationID int                `json:"organization_id"`
+	Initiator      string             `json:"initiator"`
+	Details        string             `json:"details"`
+	AccountID      *int               `json:"account_id,omitempty"`
+	DerivationPath string             `json:"derivation_path,omitempty"`
+	Payload        TransactionPayload `json:"payload"`
+	PayloadHash    string             `json:"payload_hash"`
+	Message        string             `json:"message"`
 }
 
 // TransactionConfirmationRequest is the payload for confirming a transaction.
+// The confirming address is the authenticated caller, and Signature is its
+// signature over the approval message, which names the organization, the
+// transaction and its payload hash:
+//
+//	mpc-backend approve
+//	organization: <organization id>
+//	transaction: <transaction id>
+//	payload: <0x prefixed payload hash>
 type TransactionConfirmationRequest struct {
-	OrganizationName string `json:"organization_name"`
-	Address          string `json:"address"`
+	TransactionID int    `json:"transaction_id"`
+	Signature     string `json:"signature"`
+	Scheme        string `json:"scheme"`
+}
+
+// TransactionRejectionRequest is the payload for rejecting a transaction. Signature
+// is the caller's signature over the approval message with "approve" replaced
+// by "reject", so an approval can never be replayed as a rejection.
+type TransactionRejectionRequest struct {
+	TransactionID int    `json:"transaction_id"`
+	Signature     string `json:"signature"`
+	Scheme        string `json:"scheme"`
+}
+
+// TransactionConfirmationResponse reports the progress of a transaction after a confirmation.
+type TransactionConfirmationResponse struct {
+	TransactionID int               `json:"transaction_id"`
+	Status        TransactionStatus `json:"status"`
+	Confirmations int               `json:"confirmations"`
+	Threshold     int               `json:"threshold"`
+}
+
+// TransactionStatus is the lifecycle state of a persisted transaction.
+type TransactionStatus string
+
+const (
+	TransactionPending   TransactionStatus = "pending"
+	TransactionApproved  TransactionStatus = "approved"
+	TransactionSigning   TransactionStatus = "signing"
+	TransactionSigned    TransactionStatus = "signed"
+	TransactionBroadcast TransactionStatus = "broadcast"
+	TransactionRejected  TransactionStatus = "rejected"
+	TransactionExpired   TransactionStatus = "expired"
+	TransactionCancelled TransactionStatus = "cancelled"
+	// TransactionApplied is the final state of an approved membership proposal.
+	TransactionApplied TransactionStatus = "applied"
+)
+
+// Transaction is a transaction proposal as stored in the database. A
+// transaction of a derived account is signed with the child key at
+// DerivationPath rather than the organization's group key.
+type Transaction struct {
+	ID             int                `json:"id"`
+	OrganizationID int                `json:"organization_id"`
+	Initiator      string             `json:"initiator"`
+	Details        string             `json:"details"`
+	AccountID      *int               `json:"account_id,omitempty"`
+	DerivationPath string             `json:"derivation_path,omitempty"`
+	Payload        TransactionPayload `json:"payload"`
+	PayloadHash    string             `json:"payload_hash"`
+	Signature      string             `json:"signature,omitempty"`
+	Status         TransactionStatus  `json:"status"`
+	Threshold      int                `json:"threshold"`
+	Confirmations  int                `json:"confirmations"`
+	KeyEpoch       int                `json:"key_epoch"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+	ExpiresAt      time.Time          `json:"expires_at"`
+}
+
+// TransactionStatusChange is a single entry of a transaction's audit trail.
+type TransactionStatusChange struct {
+	From      *TransactionStatus `json:"from"`
+	To        TransactionStatus  `json:"to"`
+	Actor     string             `json:"actor"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// Approval is a participant's approval of a transaction.
+type Approval struct {
+	Address   string    `json:"address"`
+	Signature string    `json:"signature"`
+	Scheme    string    `json:"scheme"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransactionProgress is broadcast to an organization whenever a transaction collects an approval or rejection.
+type TransactionProgress struct {
+	TransactionID  int      `json:"transaction_id"`
+	OrganizationID int      `json:"organization_id"`
+	Confirmations  int      `json:"confirmations"`
+	Threshold      int      `json:"threshold"`
+	Approved       []string `json:"approved"`
+	Rejected       []string `json:"rejected"`
+	Awaiting       []string `json:"awaiting"`
+	Message        string   `json:"message"`
+}
+
+// TransactionCancelRequest is the payload for cancelling a pending transaction.
+// Only the initiator of the transaction may cancel it.
+type TransactionCancelRequest struct {
+	TransactionID int `json:"transaction_id"`
+}
+
+// AuthChallengeRequest asks the server for a nonce to sign.
+type AuthChallengeRequest struct {
+	Address string `json:"address"`
+}
+
+// AuthChallengeResponse carries the message the client has to sign with the address key.
+type AuthChallengeResponse struct {
+	Nonce     string    `json:"nonce"`
+	Message   string    `json:"message"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// AuthLoginRequest exchanges a signed challenge for a session token.
+type AuthLoginRequest struct {
+	Address   string `json:"address"`
+	Nonce     string `json:"nonce"`
+	Signature string `json:"signature"`
+	Scheme    string `json:"scheme"`
+}
+
+// AuthLoginResponse holds the session token to send as a bearer token.
+type AuthLoginResponse struct {
+	Token     string    `json:"token"`
+	Address   string    `json:"address"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CeremonyStatus is the state of a multi-party ceremony session.
+type CeremonyStatus string
+
+const (
+	CeremonyRunning   CeremonyStatus = "running"
+	CeremonyCompleted CeremonyStatus = "completed"
+	CeremonyAborted   CeremonyStatus = "aborted"
+)
+
+// CeremonySession describes a multi-round protocol run between participants, such as key generation.
+// In a reshare, OldParticipants hold the shares being replaced and NewParticipants receive the new
+// ones; Participants is the union of both.
+type CeremonySession struct {
+	ID              string         `json:"id"`
+	Kind            string         `json:"kind"`
+	OrganizationID  int            `json:"organization_id"`
+	TransactionID   int            `json:"transaction_id,omitempty"`
+	Initiator       string         `json:"initiator"`
+	Participants    []string       `json:"participants"`
+	OldParticipants []string       `json:"old_participants,omitempty"`
+	NewParticipants []string       `json:"new_participants,omitempty"`
+	Subject         string         `json:"subject,omitempty"`
+	Status          CeremonyStatus `json:"status"`
+	Round           int            `json:"round"`
+	Result          string         `json:"result,omitempty"`
+	Blamed          []string       `json:"blamed,omitempty"`
+	Reason          string         `json:"reason,omitempty"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	// Instance is the server instance running the session, which the frames of
+	// its participants are forwarded to.
+	Instance string `json:"-"`
+}
+
+// CeremonyMessage is a protocol round message relayed between participants.
+// An empty To broadcasts the message to every other participant. From is
+// filled in by the server.
+type CeremonyMessage struct {
+	SessionID string          `json:"session_id"`
+	Round     int             `json:"round"`
+	From      string          `json:"from"`
+	To        string          `json:"to,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Envelope is an end-to-end encrypted point-to-point message between session
+// participants. The hub checks and relays the routing fields but never reads
+// the ciphertext.
+type Envelope struct {
+	SessionID  string `json:"session_id"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Round      int    `json:"round"`
+	Sequence   uint64 `json:"sequence"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptionKey is the X25519 public key an address receives envelopes with.
+type EncryptionKey struct {
+	Address   string    `json:"address"`
+	PublicKey string    `json:"public_key"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CeremonyResult is sent by a participant once it has finished the protocol,
+// e.g. with the group public key after key generation.
+type CeremonyResult struct {
+	SessionID string `json:"session_id"`
+	Result    string `json:"result"`
+}
+
+// CeremonyEvent notifies participants about the progress of a session.
+type CeremonyEvent struct {
+	Event   string          `json:"event"`
+	Session CeremonySession `json:"session"`
+}
+
+// ProtocolVersion is the version of the WebSocket frame format.
+const ProtocolVersion = 1
+
+// Frame types a client may send.
+const (
+	FrameTransactionConfirm = "transaction.confirm"
+	FrameTransactionReject  = "transaction.reject"
+	FrameSubscribe          = "subscribe"
+	FrameUnsubscribe        = "unsubscribe"
+	FrameAck                = "ack"
+	FrameCeremonyMessage    = "ceremony.message"
+	FrameCeremonyEnvelope   = "ceremony.envelope"
+	FrameCeremonyResult     = "ceremony.result"
+)
+
+// Frame types the server sends. Ceremony messages and envelopes are relayed
+// under the same type they were sent with.
+const (
+	FrameReply                   = "reply"
+	FrameError                   = "error"
+	FrameTransactionNotification = "transaction.notification"
+	FrameTransactionProgress     = "transaction.progress"
+	FrameCeremonyEvent           = "ceremony.event"
+	FramePresence                = "presence"
+	FrameQueued                  = "queued"
+	FrameResyncRequired          = "resync_required"
+	FrameInvitation              = "invitation"
+	FrameInvitationUpdate        = "invitation.update"
+	FrameMembershipChanged       = "organization.membership_changed"
+	FrameKeyReshared             = "organization.key_reshared"
+)
+
+// Frame is the envelope of every WebSocket message in either direction.
+// Replies and errors carry the ID of the client frame they answer in CorrelationID.
+// Organization events carry the organization and their sequence number within
+// it; a client may see an event twice, e.g. live and from its offline queue,
+// and should drop sequence numbers it has already seen. Messages sent for an
+// outbox event keep the same ID when the event is retried.
+type Frame struct {
+	Version        int             `json:"v"`
+	Type           string          `json:"type"`
+	ID             string          `json:"id,omitempty"`
+	CorrelationID  string          `json:"correlation_id,omitempty"`
+	OrganizationID int             `json:"organization_id,omitempty"`
+	Seq            int64           `json:"seq,omitempty"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+}
+
+// Error codes carried by error frames.
+const (
+	ErrorMalformed          = "malformed"
+	ErrorUnsupportedVersion = "unsupported_version"
+	ErrorUnknownType        = "unknown_type"
+	ErrorInvalidPayload     = "invalid_payload"
+	ErrorRejected           = "rejected"
+)
+
+// FrameErrorPayload is the payload of an error frame.
+type FrameErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// SubscribeRequest is the payload of subscribe and unsubscribe frames. When
+// LastSeq is set on a subscribe frame, the organization events after it are
+// sent before live events resume.
+type SubscribeRequest struct {
+	OrganizationID int    `json:"organization_id"`
+	LastSeq        *int64 `json:"last_seq,omitempty"`
+}
+
+// OrganizationEvent is a stored organization event frame.
+type OrganizationEvent struct {
+	Seq   int64           `json:"seq"`
+	Frame json.RawMessage `json:"frame"`
+}
+
+// ResyncRequired tells a client that the events it missed in an organization
+// are no longer retained and it has to refetch the organization's state.
+type ResyncRequired struct {
+	OrganizationID int   `json:"organization_id"`
+	LastSeq        int64 `json:"last_seq"`
+}
+
+// Subscription is the reply to subscribe and unsubscribe frames.
+type Subscription struct {
+	OrganizationID int  `json:"organization_id"`
+	Subscribed     bool `json:"subscribed"`
+}
+
+// QueuedMessage is a message stored while its recipient was offline. It is
+// replayed on reconnect until the client acknowledges its ID.
+type QueuedMessage struct {
+	ID        int64           `json:"id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AckRequest acknowledges queued messages so they are not replayed again.
+type AckRequest struct {
+	IDs []int64 `json:"ids"`
+}
+
+// AckResponse reports how many queued messages were acknowledged.
+type AckResponse struct {
+	Acknowledged int64 `json:"acknowledged"`
+}
+
+// HubStats exposes the connection count and slow consumer counters of the hub.
+type HubStats struct {
+	Connections int   `json:"connections"`
+	Dropped     int64 `json:"dropped"`
+	Evicted     int64 `json:"evicted"`
+}
+
+// ParticipantPresence tells whether a participant currently has an open connection.
+type ParticipantPresence struct {
+	Address  string     `json:"address"`
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// OrganizationPresence lists the presence of every participant of an organization.
+// Reachable reports whether enough participants are online to meet the threshold.
+type OrganizationPresence struct {
+	OrganizationID int                   `json:"organization_id"`
+	Threshold      int                   `json:"threshold"`
+	Online         int                   `json:"online"`
+	Reachable      bool                  `json:"reachable"`
+	Participants   []ParticipantPresence `json:"participants"`
+}
+
+// PresenceEvent is sent to an organization room when a participant comes online or goes offline.
+type PresenceEvent struct {
+	Event          string `json:"event"`
+	OrganizationID int    `json:"organization_id"`
+	Address        string `json:"address"`
+	Online         bool   `json:"online"`
+}
+
+// WebhookRequest creates or updates a webhook subscription. An empty event
+// list subscribes to every event. A secret is generated when none is given.
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+// Webhook is an organization's subscription to events delivered over HTTP.
+// The secret is only returned when the webhook is created.
+type Webhook struct {
+	ID             int       `json:"id"`
+	OrganizationID int       `json:"organization_id"`
+	URL            string    `json:"url"`
+	Events         []string  `json:"events"`
+	Secret         string    `json:"secret,omitempty"`
+	Active         bool      `json:"active"`
+	CreatedBy      string    `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// WebhookDeliveryStatus is the state of a single webhook delivery.
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an entry of a webhook's delivery log.
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	WebhookID      int                   `json:"webhook_id"`
+	Event          string                `json:"event"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	LastStatusCode int                   `json:"last_status_code"`
+	LastError      string                `json:"last_error"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// WebhookEvent is the body posted to webhook endpoints.
+type WebhookEvent struct {
+	Event          string      `json:"event"`
+	OrganizationID int         `json:"organization_id"`
+	Transaction    Transaction `json:"transaction"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+// PendingWebhookDelivery is a due delivery together with where and how to send it.
+type PendingWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
+
+// Domain events recorded in the outbox in the same database transaction as
+// the change they describe.
+const (
+	EventInvitationCreated           = "invitation.created"
+	EventInvitationAccepted          = "invitation.accepted"
+	EventInvitationDeclined          = "invitation.declined"
+	EventInvitationExpired           = "invitation.expired"
+	EventTransactionInitiated        = "transaction.initiated"
+	EventTransactionApproval         = "transaction.approval"
+	EventTransactionRejection        = "transaction.rejection"
+	EventTransactionThresholdReached = "transaction.threshold_reached"
+	EventTransactionRejected         = "transaction.rejected"
+	EventTransactionCancelled        = "transaction.cancelled"
+	EventTransactionSigned           = "transaction.signed"
+	EventTransactionExpired          = "transaction.expired"
+	EventTransactionApplied          = "transaction.applied"
+	EventMembershipChanged           = "organization.membership_changed"
+	EventKeyReshared                 = "organization.key_reshared"
+)
+
+// OutboxStatus is the dispatch state of an outbox event.
+type OutboxStatus string
+
+const (
+	OutboxPending    OutboxStatus = "pending"
+	OutboxDispatched OutboxStatus = "dispatched"
+	OutboxFailed     OutboxStatus = "failed"
+)
+
+// OutboxEvent is a domain event waiting to be handed to the hub, webhooks and
+// the offline queue. The payload is the invitation for invitation events, the
+// change for membership events and the transaction for transaction events.
+type OutboxEvent struct {
+	ID             int64
+	OrganizationID int
+	Event          string
+	Actor          string
+	Payload        json.RawMessage
+	Attempts       int
+	CreatedAt      time.Time
 }
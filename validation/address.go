@@ -0,0 +1,107 @@
+package validation
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mpc-backend/wallet"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownAddressFormat = errors.New("not an address in any supported format")
+
+// AddressFormat recognizes and normalizes one kind of participant address.
+type AddressFormat interface {
+	// Match reports whether s is meant as an address of this format, even if it is malformed.
+	Match(s string) bool
+	// Normalize returns the canonical spelling of an address, or why it is malformed.
+	Normalize(s string) (string, error)
+}
+
+// Addresses normalizes addresses with the first registered format that matches them.
+type Addresses struct {
+	mu      sync.RWMutex
+	formats []AddressFormat
+}
+
+// NewAddresses creates a registry with the formats of the built-in signature
+// schemes registered: EVM addresses and ed25519 public keys.
+func NewAddresses() *Addresses {
+	a := &Addresses{}
+	a.Register(EVMAddress{})
+	a.Register(Ed25519Address{})
+	return a
+}
+
+// Register adds a format, tried after the ones registered before it.
+func (a *Addresses) Register(f AddressFormat) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.formats = append(a.formats, f)
+}
+
+// Normalize trims an address and returns its canonical spelling.
+func (a *Addresses) Normalize(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, f := range a.formats {
+		if f.Match(s) {
+			return f.Normalize(s)
+		}
+	}
+	return "", ErrUnknownAddressFormat
+}
+
+// NormalizeAddresses normalizes a list of addresses, adding malformed ones to
+// errs under field[i].
+func NormalizeAddresses(field string, list []string, addresses *Addresses, errs *Errors) []string {
+	out := make([]string, 0, len(list))
+	for i, s := range list {
+		addr, err := addresses.Normalize(s)
+		if err != nil {
+			errs.Add(fmt.Sprintf("%s[%d]", field, i), "%v", err)
+			continue
+		}
+		out = append(out, addr)
+	}
+	return out
+}
+
+// EVMAddress is a 0x prefixed 20 byte hex address, normalized to its EIP-55
+// checksum. Mixed case input must carry a valid checksum.
+type EVMAddress struct{}
+
+func (EVMAddress) Match(s string) bool {
+	return (strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X")) && len(s) == 42
+}
+
+func (EVMAddress) Normalize(s string) (string, error) {
+	body := s[2:]
+	if _, err := hex.DecodeString(body); err != nil {
+		return "", errors.New("EVM address is not hex encoded")
+	}
+	checksummed := wallet.ChecksumAddress("0x" + body)
+	if body != strings.ToLower(body) && body != strings.ToUpper(body) && "0x"+body != checksummed {
+		return "", fmt.Errorf("EVM address has an invalid EIP-55 checksum, expected %s", checksummed)
+	}
+	return checksummed, nil
+}
+
+// Ed25519Address is a hex encoded 32 byte ed25519 public key with an optional
+// 0x prefix, normalized to lower case without the prefix.
+type Ed25519Address struct{}
+
+func (Ed25519Address) Match(s string) bool {
+	return len(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")) == 64
+}
+
+func (Ed25519Address) Normalize(s string) (string, error) {
+	body := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X"))
+	if _, err := hex.DecodeString(body); err != nil {
+		return "", errors.New("ed25519 public key is not hex encoded")
+	}
+	return body, nil
+}
@@ -0,0 +1,76 @@
+package validation
+
+import (
+	"fmt"
+	"mpc-backend/types"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MinNameLength = 3
+	MaxNameLength = 64
+)
+
+// namePattern allows letters, digits, spaces, dots, dashes and underscores,
+// starting with a letter or digit.
+var namePattern = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} ._-]*$`)
+
+// CreateOrganization checks a request to create an organization and returns it
+// with the name trimmed and the participant addresses normalized. The name must
+// follow the naming rules, every participant must be listed once and the
+// threshold must be between 1 and the number of participants.
+func CreateOrganization(req types.CreateOrganizationRequest, addresses *Addresses) (types.CreateOrganizationRequest, error) {
+	var errs Errors
+	out := types.CreateOrganizationRequest{
+		Name:         strings.TrimSpace(req.Name),
+		Threshold:    req.Threshold,
+		Participants: make([]types.Participant, 0, len(req.Participants)),
+	}
+
+	if msg := checkName(out.Name); msg != "" {
+		errs.Add("name", msg)
+	}
+
+	if len(req.Participants) == 0 {
+		errs.Add("participants", "at least one participant is required")
+	}
+	seen := make(map[string]int, len(req.Participants))
+	for i, p := range req.Participants {
+		field := fmt.Sprintf("participants[%d].address", i)
+		addr, err := addresses.Normalize(p.Address)
+		if err != nil {
+			errs.Add(field, "%v", err)
+			continue
+		}
+		if first, dup := seen[addr]; dup {
+			errs.Add(field, "duplicates participants[%d]", first)
+			continue
+		}
+		seen[addr] = i
+		out.Participants = append(out.Participants, types.Participant{Address: addr})
+	}
+
+	switch {
+	case req.Threshold < 1:
+		errs.Add("threshold", "must be at least 1")
+	case len(req.Participants) > 0 && req.Threshold > len(req.Participants):
+		errs.Add("threshold", "must not exceed the %d participants", len(req.Participants))
+	}
+
+	return out, errs.Err()
+}
+
+func checkName(name string) string {
+	n := utf8.RuneCountInString(name)
+	switch {
+	case n == 0:
+		return "is required"
+	case n < MinNameLength || n > MaxNameLength:
+		return fmt.Sprintf("must be between %d and %d characters long", MinNameLength, MaxNameLength)
+	case !namePattern.MatchString(name):
+		return "may only contain letters, digits, spaces, dots, dashes and underscores, and must start with a letter or digit"
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+// Package validation checks and normalizes user input before it reaches the
+// database. Problems are reported per field, so clients can point at them.
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a problem with a single field of a request. Field is the JSON
+// path of the field, e.g. "participants[1].address".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects the field errors found in a request.
+type Errors []FieldError
+
+// Add records a problem with a field.
+func (e *Errors) Add(field, format string, args ...interface{}) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns the collected errors, or nil if there are none.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, f := range e {
+		parts = append(parts, f.Field+": "+f.Message)
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Response is the body of a 422 Unprocessable Entity answer.
+type Response struct {
+	Error  string `json:"error"`
+	Fields Errors `json:"fields"`
+}
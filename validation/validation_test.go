@@ -0,0 +1,144 @@
+package validation
+
+import (
+	"errors"
+	"mpc-backend/types"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	const key = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	tests := []struct {
+		in   string
+		want string
+		err  string
+	}{
+		{in: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", want: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{in: "0X5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED", want: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{in: " 0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed\n", want: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{in: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD", err: "invalid EIP-55 checksum"},
+		{in: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beagd", err: "not hex encoded"},
+		{in: key, want: key},
+		{in: "0x" + strings.ToUpper(key), want: key},
+		{in: "zz" + key[2:], err: "not hex encoded"},
+		{in: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1bea", err: ErrUnknownAddressFormat.Error()},
+		{in: "", err: ErrUnknownAddressFormat.Error()},
+	}
+	addresses := NewAddresses()
+	for _, tt := range tests {
+		got, err := addresses.Normalize(tt.in)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Normalize(%q): got %q, %v, want an error containing %q", tt.in, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"abc", true},
+		{"Treasury 2024", true},
+		{"ops.team_a-1", true},
+		{"Zürich Fonds", true},
+		{"財務部", true},
+		{strings.Repeat("a", MaxNameLength), true},
+		{"", false},
+		{"ab", false},
+		{strings.Repeat("a", MaxNameLength+1), false},
+		{strings.Repeat("é", MaxNameLength+1), false},
+		{"-team", false},
+		{" team", false},
+		{"team/ops", false},
+		{"team\tops", false},
+		{"<script>", false},
+	}
+	for _, tt := range tests {
+		if msg := checkName(tt.name); (msg == "") != tt.valid {
+			t.Errorf("checkName(%q) = %q, want valid %v", tt.name, msg, tt.valid)
+		}
+	}
+}
+
+func TestCreateOrganization(t *testing.T) {
+	const (
+		a = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+		b = "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359"
+	)
+	participants := func(addresses ...string) []types.Participant {
+		out := make([]types.Participant, len(addresses))
+		for i, address := range addresses {
+			out[i] = types.Participant{Address: address}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		req    types.CreateOrganizationRequest
+		want   types.CreateOrganizationRequest
+		fields []string
+	}{
+		{
+			name: "normalized",
+			req:  types.CreateOrganizationRequest{Name: "  Treasury ", Threshold: 2, Participants: participants(strings.ToLower(a), b)},
+			want: types.CreateOrganizationRequest{Name: "Treasury", Threshold: 2, Participants: participants(a, b)},
+		},
+		{
+			name: "single participant",
+			req:  types.CreateOrganizationRequest{Name: "Solo", Threshold: 1, Participants: participants(a)},
+			want: types.CreateOrganizationRequest{Name: "Solo", Threshold: 1, Participants: participants(a)},
+		},
+		{
+			name:   "duplicate in another spelling",
+			req:    types.CreateOrganizationRequest{Name: "Treasury", Threshold: 1, Participants: participants(a, strings.ToLower(a))},
+			fields: []string{"participants[1].address"},
+		},
+		{
+			name:   "threshold above participants",
+			req:    types.CreateOrganizationRequest{Name: "Treasury", Threshold: 3, Participants: participants(a, b)},
+			fields: []string{"threshold"},
+		},
+		{
+			name:   "everything wrong",
+			req:    types.CreateOrganizationRequest{Name: "x", Threshold: 0, Participants: participants("0x1234")},
+			fields: []string{"name", "participants[0].address", "threshold"},
+		},
+		{
+			name:   "no participants",
+			req:    types.CreateOrganizationRequest{Name: "Treasury", Threshold: 1},
+			fields: []string{"participants"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := CreateOrganization(tt.req, NewAddresses())
+		if len(tt.fields) == 0 {
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: got %+v, %v, want %+v", tt.name, got, err, tt.want)
+			}
+			continue
+		}
+
+		var errs Errors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: got %v, want validation errors", tt.name, err)
+			continue
+		}
+		var fields []string
+		for _, e := range errs {
+			fields = append(fields, e.Field)
+		}
+		if !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("%s: errors on %v, want %v", tt.name, fields, tt.fields)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+var (
+	ErrInvalidPath  = errors.New("invalid derivation path")
+	ErrHardenedPath = errors.New("hardened derivation needs the private key and is not supported")
+)
+
+const (
+	// hardenedOffset is the first hardened child index.
+	hardenedOffset = 1 << 31
+	// maxDepth is the deepest path BIP32 serializes.
+	maxDepth = 255
+)
+
+// chainCodeDomain separates the master chain code of a group key from other hashes of it.
+const chainCodeDomain = "mpc-backend chain code"
+
+// ParsePath parses a BIP32 path such as "m/0/1" into its child indexes.
+// Hardened steps, marked with ' or h, are refused.
+func ParsePath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("%w: %q must start with m", ErrInvalidPath, path)
+	}
+	if len(parts)-1 > maxDepth {
+		return nil, fmt.Errorf("%w: deeper than %d levels", ErrInvalidPath, maxDepth)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") || strings.HasSuffix(p, "H") {
+			return nil, fmt.Errorf("%w: %q", ErrHardenedPath, path)
+		}
+		i, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || i >= hardenedOffset {
+			return nil, fmt.Errorf("%w: bad index %q", ErrInvalidPath, p)
+		}
+		indexes = append(indexes, uint32(i))
+	}
+	return indexes, nil
+}
+
+// FormatPath writes child indexes as a BIP32 path.
+func FormatPath(indexes []uint32) string {
+	var b strings.Builder
+	b.WriteString("m")
+	for _, i := range indexes {
+		b.WriteString("/")
+		b.WriteString(strconv.FormatUint(uint64(i), 10))
+	}
+	return b.String()
+}
+
+// Derive returns the public key at a non-hardened path below a group key.
+// Key generation yields no chain code, so the master chain code is the
+// SHA-256 of a domain tag and the compressed group key, which participants
+// compute the same way.
+func Derive(pub *secp256k1.PublicKey, path []uint32) (*secp256k1.PublicKey, error) {
+	chainCode := sha256.Sum256(append([]byte(chainCodeDomain), pub.SerializeCompressed()...))
+	code := chainCode[:]
+
+	var err error
+	for _, index := range path {
+		pub, code, err = deriveChild(pub, code, index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pub, nil
+}
+
+// deriveChild is BIP32's CKDpub for a non-hardened index.
+func deriveChild(parent *secp256k1.PublicKey, chainCode []byte, index uint32) (*secp256k1.PublicKey, []byte, error) {
+	data := make([]byte, 0, 37)
+	data = append(data, parent.SerializeCompressed()...)
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	var tweak secp256k1.ModNScalar
+	if overflow := tweak.SetByteSlice(sum[:32]); overflow {
+		return nil, nil, fmt.Errorf("%w: index %d yields no valid key, use the next one", ErrInvalidPath, index)
+	}
+
+	var tweakPoint, parentPoint, child secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&tweak, &tweakPoint)
+	parent.AsJacobian(&parentPoint)
+	secp256k1.AddNonConst(&tweakPoint, &parentPoint, &child)
+	if child.Z.IsZero() {
+		return nil, nil, fmt.Errorf("%w: index %d yields no valid key, use the next one", ErrInvalidPath, index)
+	}
+	child.ToAffine()
+
+	return secp256k1.NewPublicKey(&child.X, &child.Y), sum[32:], nil
+}
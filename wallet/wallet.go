@@ -0,0 +1,107 @@
+// Package wallet derives the on-chain identity of an organization's group key:
+// child keys along non-hardened BIP32 paths and the addresses a key has on the
+// supported chains. Only public keys are involved, so derivation runs on the
+// server; participants apply the same tweaks to their shares when they sign.
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mpc-backend/auth"
+	"mpc-backend/types"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/ripemd160"
+)
+
+var (
+	ErrInvalidKey     = errors.New("not a valid secp256k1 public key")
+	ErrUnknownNetwork = errors.New("unknown bitcoin network")
+)
+
+// networkHRPs maps the Bitcoin networks to the human readable part of their bech32 addresses.
+var networkHRPs = map[string]string{
+	"mainnet": "bc",
+	"testnet": "tb",
+	"signet":  "tb",
+	"regtest": "bcrt",
+}
+
+// BitcoinHRP returns the bech32 human readable part of a Bitcoin network.
+func BitcoinHRP(network string) (string, error) {
+	hrp, ok := networkHRPs[network]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownNetwork, network)
+	}
+	return hrp, nil
+}
+
+// ParsePublicKey parses a hex encoded compressed or uncompressed secp256k1
+// public key with an optional 0x prefix.
+func ParsePublicKey(s string) (*secp256k1.PublicKey, error) {
+	b, err := auth.DecodeHex(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	pub, err := secp256k1.ParsePubKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+	return pub, nil
+}
+
+// EncodePublicKey returns the hex encoded compressed form of a public key.
+func EncodePublicKey(pub *secp256k1.PublicKey) string {
+	return hex.EncodeToString(pub.SerializeCompressed())
+}
+
+// Addresses returns the addresses of a public key on every supported chain.
+func Addresses(pub *secp256k1.PublicKey, network string) (types.ChainAddresses, error) {
+	btc, err := P2WPKHAddress(pub, network)
+	if err != nil {
+		return types.ChainAddresses{}, err
+	}
+	return types.ChainAddresses{
+		EVM:     EVMAddress(pub),
+		Bitcoin: btc,
+	}, nil
+}
+
+// EVMAddress returns the EIP-55 checksummed EVM address of a public key.
+func EVMAddress(pub *secp256k1.PublicKey) string {
+	return ChecksumAddress(auth.PublicKeyToAddress(pub.SerializeUncompressed()))
+}
+
+// ChecksumAddress applies the EIP-55 mixed case checksum to a 0x prefixed hex address.
+func ChecksumAddress(address string) string {
+	lower := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	hash := hex.EncodeToString(auth.Keccak256([]byte(lower)))
+
+	out := []byte(lower)
+	for i, c := range out {
+		if c >= 'a' && c <= 'f' && hash[i] >= '8' {
+			out[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(out)
+}
+
+// P2WPKHAddress returns the native segwit (version 0, bech32) pay to witness
+// public key hash address of a public key on a Bitcoin network.
+func P2WPKHAddress(pub *secp256k1.PublicKey, network string) (string, error) {
+	hrp, err := BitcoinHRP(network)
+	if err != nil {
+		return "", err
+	}
+
+	sha := sha256.Sum256(pub.SerializeCompressed())
+	h := ripemd160.New()
+	h.Write(sha[:])
+	program := h.Sum(nil)
+
+	data := append([]byte{0}, convertBits(program, 8, 5, true)...)
+	return bech32Encode(hrp, data), nil
+}
@@ -0,0 +1,165 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// generator is the compressed public key of private key 1, used by the BIP173 examples.
+const generator = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+
+func TestChecksumAddress(t *testing.T) {
+	// The examples of EIP-55.
+	tests := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for _, want := range tests {
+		for _, in := range []string{want, "0x" + strings.ToLower(want[2:]), "0x" + strings.ToUpper(want[2:])} {
+			if got := ChecksumAddress(in); got != want {
+				t.Errorf("ChecksumAddress(%s) = %s, want %s", in, got, want)
+			}
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	pub, err := ParsePublicKey("0x" + generator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		network string
+		want    string
+	}{
+		// The P2WPKH examples of BIP173.
+		{"mainnet", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"testnet", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{"signet", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+	}
+	for _, tt := range tests {
+		got, err := Addresses(pub, tt.network)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.network, err)
+			continue
+		}
+		if got.Bitcoin != tt.want {
+			t.Errorf("%s: bitcoin address = %s, want %s", tt.network, got.Bitcoin, tt.want)
+		}
+		if got.EVM != "0x7E5F4552091A69125d5DfCb7b8C2659029395Bdf" {
+			t.Errorf("%s: evm address = %s, want the address of private key 1", tt.network, got.EVM)
+		}
+	}
+	if _, err := Addresses(pub, "litecoin"); !errors.Is(err, ErrUnknownNetwork) {
+		t.Errorf("unknown network: got %v, want ErrUnknownNetwork", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	uncompressed := "0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8"
+	for _, s := range []string{generator, "0x" + generator, uncompressed} {
+		pub, err := ParsePublicKey(s)
+		if err != nil {
+			t.Errorf("ParsePublicKey(%s): unexpected error: %v", s, err)
+			continue
+		}
+		if got := EncodePublicKey(pub); got != generator {
+			t.Errorf("EncodePublicKey = %s, want %s", got, generator)
+		}
+	}
+	for _, s := range []string{"", "zz", generator[:64], "05" + generator[2:]} {
+		if _, err := ParsePublicKey(s); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("ParsePublicKey(%q): got %v, want ErrInvalidKey", s, err)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []uint32
+		err  error
+	}{
+		{"m", []uint32{}, nil},
+		{" m/0/1 ", []uint32{0, 1}, nil},
+		{"m/2147483647", []uint32{2147483647}, nil},
+		{"m/2147483648", nil, ErrInvalidPath},
+		{"m/0'", nil, ErrHardenedPath},
+		{"m/44h/0", nil, ErrHardenedPath},
+		{"0/1", nil, ErrInvalidPath},
+		{"m/", nil, ErrInvalidPath},
+		{"m/-1", nil, ErrInvalidPath},
+		{"m/x", nil, ErrInvalidPath},
+	}
+	for _, tt := range tests {
+		got, err := ParsePath(tt.path)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ParsePath(%q): got %v, want %v", tt.path, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePath(%q) = %v (%v), want %v", tt.path, got, err, tt.want)
+			continue
+		}
+		if formatted := FormatPath(got); formatted != strings.TrimSpace(tt.path) {
+			t.Errorf("FormatPath(%v) = %s, want %s", got, formatted, strings.TrimSpace(tt.path))
+		}
+	}
+}
+
+// TestDeriveChild follows BIP32 test vector 2 from m to m/0.
+func TestDeriveChild(t *testing.T) {
+	parent, err := ParsePublicKey("03cbcaa9c98c877a26977d00825c956a238e8dddfbd322cce4f74b0b5bd6ace4a7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chainCode, _ := hex.DecodeString("60499f801b896d83179a4374aeb7822aaeaceaa0db1f85ee3e904c4defbd9689")
+
+	child, code, err := deriveChild(parent, chainCode, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := EncodePublicKey(child); got != "02fc9e5af0ac8d9b3cecfe2a888e2117ba3d089d8585886c9c826b6b22a98d12ea" {
+		t.Errorf("m/0 public key = %s", got)
+	}
+	if got := hex.EncodeToString(code); got != "f0909affaa7ee7abe5dd4e100598d4dc53cd709d5a5c2cac40e7412f232f7c9c" {
+		t.Errorf("m/0 chain code = %s", got)
+	}
+}
+
+func TestDerive(t *testing.T) {
+	pub, err := ParsePublicKey(generator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := Derive(pub, nil)
+	if err != nil || !root.IsEqual(pub) {
+		t.Errorf("Derive(m) = %v (%v), want the key itself", root, err)
+	}
+
+	child, err := Derive(pub, []uint32{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Derive(pub, []uint32{1, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.IsEqual(other) || child.IsEqual(pub) {
+		t.Error("distinct paths derived the same key")
+	}
+	again, err := Derive(pub, []uint32{0, 1})
+	if err != nil || !again.IsEqual(child) {
+		t.Error("derivation is not deterministic")
+	}
+	if !child.IsOnCurve() {
+		t.Error("derived key is not on the curve")
+	}
+}
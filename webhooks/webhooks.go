@@ -0,0 +1,176 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"mpc-backend/config"
+	"mpc-backend/types"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Events a webhook can subscribe to. They are the transaction events of the outbox.
+const (
+	EventTransactionInitiated        = types.EventTransactionInitiated
+	EventTransactionApproval         = types.EventTransactionApproval
+	EventTransactionThresholdReached = types.EventTransactionThresholdReached
+	EventTransactionRejected         = types.EventTransactionRejected
+	EventTransactionCancelled        = types.EventTransactionCancelled
+	EventTransactionSigned           = types.EventTransactionSigned
+	EventTransactionExpired          = types.EventTransactionExpired
+)
+
+// Events lists every event a webhook can subscribe to.
+var Events = []string{
+	EventTransactionInitiated,
+	EventTransactionApproval,
+	EventTransactionThresholdReached,
+	EventTransactionRejected,
+	EventTransactionCancelled,
+	EventTransactionSigned,
+	EventTransactionExpired,
+}
+
+// Headers sent with every delivery. The signature is the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret, so
+// receivers can reject replays of old deliveries. The delivery ID stays the
+// same across retries and lets receivers drop duplicates.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// IsEvent reports whether name is a known event.
+func IsEvent(name string) bool {
+	for _, e := range Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign computes the signature header value of a delivery body.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Store persists the delivery log the dispatcher works through.
+type Store interface {
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]types.PendingWebhookDelivery, error)
+	RecordWebhookAttempt(id int64, status types.WebhookDeliveryStatus, statusCode int, lastError string, next time.Time) error
+}
+
+// Dispatcher posts due webhook deliveries and retries failed ones with
+// exponential backoff until they succeed or run out of attempts.
+type Dispatcher struct {
+	store  Store
+	conf   config.WebhookConf
+	client *http.Client
+}
+
+// NewDispatcher creates a Dispatcher working through the deliveries in store.
+func NewDispatcher(store Store, conf config.WebhookConf) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		conf:   conf,
+		client: &http.Client{Timeout: conf.Timeout},
+	}
+}
+
+// Run polls for due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// The lease keeps a claimed delivery from being picked up again while
+		// it is being sent; a crash mid-send makes it due again afterwards.
+		due, err := d.store.ClaimWebhookDeliveries(d.conf.BatchSize, 2*d.conf.Timeout)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to claim webhook deliveries")
+			continue
+		}
+		for _, delivery := range due {
+			d.deliver(ctx, delivery)
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery types.PendingWebhookDelivery) {
+	statusCode, err := d.post(ctx, delivery)
+	if err == nil {
+		if err := d.store.RecordWebhookAttempt(delivery.ID, types.WebhookDeliveryDelivered, statusCode, "", time.Now()); err != nil {
+			log.Error().Err(err).Int64("delivery", delivery.ID).Msg("Failed to record webhook delivery")
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	status := types.WebhookDeliveryPending
+	if attempts >= d.conf.MaxAttempts {
+		status = types.WebhookDeliveryFailed
+	}
+	log.Warn().Err(err).Int64("delivery", delivery.ID).Int("attempts", attempts).Msg("Webhook delivery failed")
+
+	if err := d.store.RecordWebhookAttempt(delivery.ID, status, statusCode, err.Error(), time.Now().Add(d.backoff(attempts))); err != nil {
+		log.Error().Err(err).Int64("delivery", delivery.ID).Msg("Failed to record webhook attempt")
+	}
+}
+
+// post sends a delivery and fails unless the receiver answers with a 2xx status.
+func (d *Dispatcher) post(ctx context.Context, delivery types.PendingWebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff is the delay before the next attempt: BackoffBase doubled per failed
+// attempt, capped at BackoffMax.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := float64(d.conf.BackoffBase) * math.Pow(2, float64(attempts-1))
+	if delay > float64(d.conf.BackoffMax) {
+		return d.conf.BackoffMax
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,181 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"mpc-backend/config"
+	"mpc-backend/types"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type attempt struct {
+	id         int64
+	status     types.WebhookDeliveryStatus
+	statusCode int
+	lastError  string
+	next       time.Time
+}
+
+type fakeStore struct {
+	mu       sync.Mutex
+	attempts []attempt
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(int, time.Duration) ([]types.PendingWebhookDelivery, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) RecordWebhookAttempt(id int64, status types.WebhookDeliveryStatus, statusCode int, lastError string, next time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, attempt{id, status, statusCode, lastError, next})
+	return nil
+}
+
+func (s *fakeStore) last(t *testing.T) attempt {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(s.attempts))
+	}
+	return s.attempts[0]
+}
+
+func testConf() config.WebhookConf {
+	return config.WebhookConf{
+		Timeout:     time.Second,
+		MaxAttempts: 3,
+		BackoffBase: time.Second,
+		BackoffMax:  10 * time.Second,
+	}
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{"whsec_test", 1700000000, `{"event":"transaction.signed"}`, "sha256=62236e22ab4cfe4ea721cd803f9c734303807e3a09c74900eb994c5893c5019a"},
+		{"", 0, "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+	for _, tt := range tests {
+		if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+			t.Errorf("Sign(%q, %d, %q) = %s, want %s", tt.secret, tt.timestamp, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDeliverSignsRequest(t *testing.T) {
+	body := []byte(`{"event":"transaction.signed"}`)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if string(got) != string(body) {
+			t.Errorf("body = %s, want %s", got, body)
+		}
+		if e := r.Header.Get(HeaderEvent); e != EventTransactionSigned {
+			t.Errorf("%s = %q, want %q", HeaderEvent, e, EventTransactionSigned)
+		}
+		if id := r.Header.Get(HeaderDelivery); id != "42" {
+			t.Errorf("%s = %q, want 42", HeaderDelivery, id)
+		}
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("%s is not a unix timestamp: %v", HeaderTimestamp, err)
+		}
+		if sig := r.Header.Get(HeaderSignature); sig != Sign("secret", timestamp, got) {
+			t.Errorf("%s = %q does not match the body", HeaderSignature, sig)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{}
+	NewDispatcher(store, testConf()).deliver(context.Background(), types.PendingWebhookDelivery{
+		WebhookDelivery: types.WebhookDelivery{ID: 42, Event: EventTransactionSigned, Payload: body},
+		URL:             receiver.URL,
+		Secret:          "secret",
+	})
+
+	a := store.last(t)
+	if a.id != 42 || a.status != types.WebhookDeliveryDelivered || a.statusCode != http.StatusNoContent || a.lastError != "" {
+		t.Errorf("recorded %+v, want a delivered attempt with status 204", a)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	tests := []struct {
+		attempts int
+		status   types.WebhookDeliveryStatus
+		delay    time.Duration
+	}{
+		{0, types.WebhookDeliveryPending, time.Second},
+		{1, types.WebhookDeliveryPending, 2 * time.Second},
+		{2, types.WebhookDeliveryFailed, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		store := &fakeStore{}
+		before := time.Now()
+		NewDispatcher(store, testConf()).deliver(context.Background(), types.PendingWebhookDelivery{
+			WebhookDelivery: types.WebhookDelivery{ID: 7, Event: EventTransactionExpired, Payload: []byte(`{}`), Attempts: tt.attempts},
+			URL:             receiver.URL,
+		})
+
+		a := store.last(t)
+		if a.status != tt.status || a.statusCode != http.StatusServiceUnavailable || a.lastError == "" {
+			t.Errorf("after %d attempts recorded %+v, want status %s with code 503 and an error", tt.attempts, a, tt.status)
+		}
+		if delay := a.next.Sub(before); delay < tt.delay || delay > tt.delay+time.Second {
+			t.Errorf("after %d attempts the next attempt is in %v, want %v", tt.attempts, delay, tt.delay)
+		}
+	}
+}
+
+func TestDeliverUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	store := &fakeStore{}
+	NewDispatcher(store, testConf()).deliver(context.Background(), types.PendingWebhookDelivery{
+		WebhookDelivery: types.WebhookDelivery{ID: 1, Payload: []byte(`{}`)},
+		URL:             url,
+	})
+
+	a := store.last(t)
+	if a.status != types.WebhookDeliveryPending || a.statusCode != 0 || a.lastError == "" {
+		t.Errorf("recorded %+v, want a pending attempt without status code", a)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(&fakeStore{}, testConf())
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{30, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}